@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"gomod.cblgh.org/lieu/crawler"
 	"gomod.cblgh.org/lieu/database"
 	"gomod.cblgh.org/lieu/ingest"
 	"gomod.cblgh.org/lieu/server"
+	"gomod.cblgh.org/lieu/types"
 	"gomod.cblgh.org/lieu/util"
 )
 
@@ -21,6 +23,9 @@ Commands
 - ingest    (ingest crawled data, generates database)
 - search    (interactive cli for searching the database)
 - host      (hosts search engine over http) 
+- check-url (explains whether the crawler would crawl a given url, e.g. lieu check-url https://example.com/tags/art)
+- db analyze (updates the statistics the database plans searches with, and shows the plans of sample searches,
+              e.g. lieu db analyze synth modular)
 
 Example:
     lieu precrawl > data/webring.txt 
@@ -51,7 +56,7 @@ func main() {
 
 	switch cmd {
 	case "help":
-		fmt.Println(help)
+		fmt.Print(help)
 	case "precrawl":
 		if config.General.URL == "https://example.com/" {
 			fmt.Println("lieu: the url is not set (example.com)")
@@ -69,6 +74,12 @@ func main() {
 			util.Exit()
 		}
 		crawler.Crawl(config)
+	case "check-url":
+		if len(os.Args) < 3 {
+			fmt.Println("lieu: missing url; usage: lieu check-url <url>")
+			util.Exit()
+		}
+		crawler.CheckURL(config, os.Args[2])
 	case "ingest":
 		if exists := util.CheckFileExists(config.Data.Source); !exists {
 			fmt.Printf("lieu: data source %s does not exist\n", config.Data.Source)
@@ -86,13 +97,22 @@ func main() {
 		if exists := util.CheckFileExists(config.Data.Database); !exists {
 			util.DatabaseDoesNotExist(config.Data.Database)
 		}
-		interactiveMode(config.Data.Database)
+		interactiveMode(config)
 	case "random":
 		if exists := util.CheckFileExists(config.Data.Database); !exists {
 			util.DatabaseDoesNotExist(config.Data.Database)
 		}
 		db := database.InitDB(config.Data.Database)
 		fmt.Println(database.GetRandomPage(db))
+	case "db":
+		if len(os.Args) < 3 || os.Args[2] != "analyze" {
+			fmt.Println("lieu: missing subcommand; usage: lieu db analyze [word ...]")
+			util.Exit()
+		}
+		if exists := util.CheckFileExists(config.Data.Database); !exists {
+			util.DatabaseDoesNotExist(config.Data.Database)
+		}
+		analyze(config, os.Args[3:])
 	case "host":
 		if exists := util.CheckFileExists(config.Data.Database); !exists {
 			util.DatabaseDoesNotExist(config.Data.Database)
@@ -107,15 +127,26 @@ func main() {
 	}
 }
 
-func interactiveMode(databasePath string) {
-	db := database.InitDB(databasePath)
+// searchTerms reduces the words of a query to the terms they are indexed as
+func searchTerms(config types.Config, query string) []string {
+	terms := util.Inflect(util.SegmentCJK(strings.Fields(util.NormalizeText(query))), nil)
+	if config.General.FoldAccents {
+		for i, term := range terms {
+			terms[i] = util.FoldAccents(term)
+		}
+	}
+	return terms
+}
+
+func interactiveMode(config types.Config) {
+	db := database.InitDB(config.Data.Database)
 	reader := bufio.NewReader(os.Stdin)
 	for {
 		fmt.Printf("> ")
 		input, err := reader.ReadString('\n')
 		util.Check(err)
 		input = strings.TrimSuffix(input, "\n")
-		pages := database.SearchWordsByScore(db, util.Inflect(strings.Fields(input)))
+		pages := database.SearchWordsByScore(db, searchTerms(config, input))
 		for _, pageData := range pages {
 			fmt.Println(pageData.URL)
 			if len(pageData.About) > 0 {
@@ -124,3 +155,32 @@ func interactiveMode(databasePath string) {
 		}
 	}
 }
+
+// analyze updates the database's statistics, then shows how it plans, and how long it takes, to search for each of the
+// words—or for the most common words of the index, if none are given—and for all of them at once
+func analyze(config types.Config, words []string) {
+	db := database.InitDB(config.Data.Database)
+	start := time.Now()
+	database.Analyze(db)
+	fmt.Printf("lieu: analyzed the database in %s\n", time.Since(start).Round(time.Millisecond))
+
+	if len(words) == 0 {
+		words = database.SampleWords(db, 3)
+	}
+	searches := make([][]string, 0, len(words)+1)
+	for _, word := range words {
+		searches = append(searches, []string{word})
+	}
+	if len(words) > 1 {
+		searches = append(searches, words)
+	}
+	for _, search := range searches {
+		terms := searchTerms(config, strings.Join(search, " "))
+		start = time.Now()
+		pages := database.SearchWordsByScore(db, terms)
+		fmt.Printf("\n%q: %d pages in %s\n", strings.Join(search, " "), len(pages), time.Since(start).Round(time.Microsecond))
+		for _, step := range database.ExplainSearch(db, terms) {
+			fmt.Println("  " + step)
+		}
+	}
+}
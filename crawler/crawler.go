@@ -5,8 +5,8 @@ import (
 	"log"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"gomod.cblgh.org/lieu/types"
@@ -27,22 +27,6 @@ func getBannedSuffixes(path string) []string {
 	return util.ReadList(path, "\n")
 }
 
-// prevents us from crawling or logging data from e.g. voluminous pages like accidentally ending up in a repo,
-// tag categories, pages with only images
-func getBannedURLParts() []*regexp.Regexp {
-	parts := []string{
-		"gallery",
-		"repo", "repos", "repository", "repositories",
-		"tags", "tag", "tagged", "t",
-		"commit", "commits",
-	}
-	patterns := make([]*regexp.Regexp, 0, len(parts))
-	for _, part := range parts {
-		patterns = append(patterns, regexp.MustCompile(fmt.Sprintf(`https?:\/\/\S+\/%s\/\S+`, part)))
-	}
-	return patterns
-}
-
 func getBoringWords(path string) []string {
 	return util.ReadList(path, "\n")
 }
@@ -73,6 +57,8 @@ func find(list []string, query string) bool {
 	return false
 }
 
+// getLink strips the fragment and query of target. links followed by the crawler have their query processed by the
+// QueryRules instead.
 func getLink(target string) string {
 	// remove anchor links
 	if strings.Contains(target, "#") {
@@ -114,8 +100,9 @@ func getDomains(links []string) ([]string, []string) {
 			continue
 		}
 		domains = append(domains, u.Hostname())
-		if len(u.Path) > 0 && (u.Path != "/" || u.Path != "index.html") {
-			pathsites = append(pathsites, l)
+		if len(u.Path) > 0 && (u.Path != "/" && u.Path != "/index.html") {
+			// compared with the normalized urls of the linked pages
+			pathsites = append(pathsites, util.NormalizeURL(l))
 		}
 	}
 	return domains, pathsites
@@ -130,38 +117,134 @@ func findSuffix(suffixes []string, query string) bool {
 	return false
 }
 
+// robotsDirectives are the indexing instructions a page gives crawlers, via <meta name="robots"> or the X-Robots-Tag
+// header
+type robotsDirectives struct {
+	noindex   bool
+	nofollow  bool
+	nosnippet bool
+}
+
+// the directives which are followed by a value, e.g. "max-snippet: -1", rather than by the directives of a crawler
+var valuedDirectives = []string{"max-snippet", "max-image-preview", "max-video-preview", "unavailable_after"}
+
+// parseRobotsDirectives reads comma separated directives such as "noindex, nofollow". directives scoped to a named
+// crawler (e.g. "otherbot: noindex") are only honored if they are scoped to lieu.
+func parseRobotsDirectives(content string, directives *robotsDirectives) {
+	content = strings.ToLower(content)
+	if i := strings.Index(content, ":"); i != -1 && !strings.Contains(content[:i], ",") && !find(valuedDirectives, strings.TrimSpace(content[:i])) {
+		if agent := strings.TrimSpace(content[:i]); agent != "lieu" && agent != "*" {
+			return
+		}
+		content = content[i+1:]
+	}
+	for _, directive := range strings.Split(content, ",") {
+		switch strings.TrimSpace(directive) {
+		case "none":
+			directives.noindex = true
+			directives.nofollow = true
+		case "noindex":
+			directives.noindex = true
+		case "nofollow":
+			directives.nofollow = true
+		case "nosnippet":
+			directives.nosnippet = true
+		}
+	}
+}
+
+func getRobotsDirectives(ctx *colly.Context) robotsDirectives {
+	if directives, ok := ctx.GetAny("robots").(robotsDirectives); ok {
+		return directives
+	}
+	return robotsDirectives{}
+}
+
+// handleRobots collects the robots directives of each page from its headers and meta tags. it has to be registered
+// before any other OnHTML callback, as colly runs them in the order they were registered.
+func handleRobots(c *colly.Collector) {
+	c.OnResponse(func(r *colly.Response) {
+		var directives robotsDirectives
+		for _, header := range r.Headers.Values("X-Robots-Tag") {
+			parseRobotsDirectives(header, &directives)
+		}
+		r.Ctx.Put("robots", directives)
+	})
+
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		directives := getRobotsDirectives(e.Request.Ctx)
+		e.DOM.Find("meta[name][content]").Each(func(_ int, s *goquery.Selection) {
+			name := strings.ToLower(s.AttrOr("name", ""))
+			if name == "robots" || name == "lieu" {
+				parseRobotsDirectives(s.AttrOr("content", ""), &directives)
+			}
+		})
+		e.Request.Ctx.Put("robots", directives)
+	})
+}
+
 func handleIndexing(c *colly.Collector, previewQueries []string, heuristics []string) {
-	c.OnHTML("meta[name=\"keywords\"]", func(e *colly.HTMLElement) {
+	// wraps the indexing callbacks, skipping pages which have asked not to be indexed
+	onIndexable := func(selector string, callback colly.HTMLCallback) {
+		c.OnHTML(selector, func(e *colly.HTMLElement) {
+			if getRobotsDirectives(e.Request.Ctx).noindex {
+				return
+			}
+			callback(e)
+		})
+	}
+
+	// let ingest know it shouldn't present any of the page's text as a preview
+	onIndexable("html", func(e *colly.HTMLElement) {
+		if getRobotsDirectives(e.Request.Ctx).nosnippet {
+			fmt.Println("robots", "nosnippet", e.Request.URL)
+		}
+	})
+
+	// explicitly marked up metadata, which ingest prefers over the heuristics below
+	onIndexable("html", collectStructuredData)
+	onIndexable("html", collectPerson)
+
+	onIndexable("meta[name=\"keywords\"]", func(e *colly.HTMLElement) {
 		fmt.Println("keywords", util.CleanText(e.Attr("content")), e.Request.URL)
 	})
 
-	c.OnHTML("meta[name=\"description\"]", func(e *colly.HTMLElement) {
+	onIndexable("meta[name=\"description\"]", func(e *colly.HTMLElement) {
 		desc := util.CleanText(e.Attr("content"))
 		if len(desc) > 0 && len(desc) < 1500 {
 			fmt.Println("desc", desc, e.Request.URL)
 		}
 	})
 
-	c.OnHTML("meta[property=\"og:description\"]", func(e *colly.HTMLElement) {
+	onIndexable("meta[property=\"og:description\"]", func(e *colly.HTMLElement) {
 		ogDesc := util.CleanText(e.Attr("content"))
 		if len(ogDesc) > 0 && len(ogDesc) < 1500 {
 			fmt.Println("og-desc", ogDesc, e.Request.URL)
 		}
 	})
 
-	c.OnHTML("html[lang]", func(e *colly.HTMLElement) {
+	onIndexable("html[lang]", func(e *colly.HTMLElement) {
 		lang := util.CleanText(e.Attr("lang"))
 		if len(lang) > 0 && len(lang) < 100 {
 			fmt.Println("lang", lang, e.Request.URL)
 		}
 	})
 
+	// pages often declare which of their many urls (http/https, with or without index.html) is the real one; ingest
+	// uses this to collapse the variants into a single page
+	onIndexable("link[rel=\"canonical\"][href]", func(e *colly.HTMLElement) {
+		canonical := util.NormalizeURL(e.Request.AbsoluteURL(e.Attr("href")))
+		if len(canonical) > 0 && canonical != util.NormalizeURL(e.Request.URL.String()) {
+			fmt.Println("canonical", canonical, e.Request.URL)
+		}
+	})
+
 	// get page title
-	c.OnHTML("title", func(e *colly.HTMLElement) {
+	onIndexable("title", func(e *colly.HTMLElement) {
 		fmt.Println("title", util.CleanText(e.Text), e.Request.URL)
 	})
 
-	c.OnHTML("body", func(e *colly.HTMLElement) {
+	onIndexable("body", func(e *colly.HTMLElement) {
 	QueryLoop:
 		for i := 0; i < len(previewQueries); i++ {
 			// After the fourth paragraph we're probably too far in to get something interesting for a preview
@@ -193,8 +276,42 @@ func handleIndexing(c *colly.Collector, previewQueries []string, heuristics []st
 		collectHeadingText("h2", e)
 		collectHeadingText("h3", e)
 	})
+
+	// art and photography sites have little paragraph text; their images' descriptions are what describes them
+	onIndexable("img[src]", func(e *colly.HTMLElement) {
+		alt := util.CleanText(e.Attr("alt"))
+		if len(alt) == 0 {
+			alt = util.CleanText(e.DOM.Closest("figure").Find("figcaption").First().Text())
+		}
+		// inline images (data: uris) can't be linked to, and would make for lines too long to be ingested
+		u, err := url.Parse(e.Request.AbsoluteURL(e.Attr("src")))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return
+		}
+		src := u.String()
+		if len(src) < maxImageSrcLength && len(alt) > 2 && len(alt) < 500 {
+			fmt.Println("img", src, alt, e.Request.URL)
+		}
+	})
+
+	onIndexable("figcaption", func(e *colly.HTMLElement) {
+		caption := util.CleanText(e.Text)
+		if len(caption) > 2 && len(caption) < 1500 {
+			fmt.Println("figcaption", caption, e.Request.URL)
+		}
+	})
+
+	onIndexable("body [title]", func(e *colly.HTMLElement) {
+		title := util.CleanText(e.Attr("title"))
+		if len(title) > 2 && len(title) < 500 {
+			fmt.Println("title-attr", title, e.Request.URL)
+		}
+	})
 }
 
+// the longest image url logged; longer ones are most likely generated
+const maxImageSrcLength = 2048
+
 func collectHeadingText(heading string, e *colly.HTMLElement) {
 	for _, headingText := range e.ChildTexts(heading) {
 		if len(headingText) < 500 {
@@ -203,6 +320,21 @@ func collectHeadingText(heading string, e *colly.HTMLElement) {
 	}
 }
 
+// getAnchorText returns the text of a link, falling back to its title or the alt text of the image it wraps
+func getAnchorText(e *colly.HTMLElement) string {
+	anchor := util.CleanText(e.Text)
+	if len(anchor) == 0 {
+		anchor = util.CleanText(e.Attr("title"))
+	}
+	if len(anchor) == 0 {
+		anchor = util.CleanText(e.ChildAttr("img", "alt"))
+	}
+	if len(anchor) > 200 {
+		return ""
+	}
+	return anchor
+}
+
 func SetupDefaultProxy(config types.Config) error {
 	// no proxy configured, go back
 	if config.General.Proxy == "" {
@@ -265,6 +397,28 @@ func Precrawl(config types.Config) {
 	}
 }
 
+// queuedURLs remembers the pages queued for crawling by their normalized urls, so that e.g. /post and /post/ are only
+// crawled once—while the page is fetched using the url it was first linked with
+type queuedURLs struct {
+	sync.Mutex
+	seen map[string]bool
+}
+
+// add returns whether link was not queued before
+func (q *queuedURLs) add(link string) bool {
+	q.Lock()
+	defer q.Unlock()
+	if q.seen == nil {
+		q.seen = make(map[string]bool)
+	}
+	key := util.NormalizeURL(link)
+	if q.seen[key] {
+		return false
+	}
+	q.seen[key] = true
+	return true
+}
+
 func Crawl(config types.Config) {
 	// setup proxy
 	err := SetupDefaultProxy(config)
@@ -276,7 +430,9 @@ func Crawl(config types.Config) {
 	domains, pathsites := getDomains(links)
 	initialDomain := config.General.URL
 
-	// TODO: introduce c2 for scraping links (with depth 1) linked to from webring domains
+	// the links leading outside of the webring are visited by a second collector once the webring has been crawled
+	var outgoing outgoingLinks
+
 	// instantiate default collector
 	c := colly.NewCollector(
 		colly.MaxDepth(3),
@@ -290,54 +446,86 @@ func Crawl(config types.Config) {
 		&queue.InMemoryQueueStorage{MaxSize: 100000},
 	)
 
+	var queued queuedURLs
 	for _, link := range links {
-		q.AddURL(link)
+		if queued.add(link) {
+			q.AddURL(link)
+		}
 	}
 
 	c.UserAgent = "Lieu"
 	c.AllowedDomains = domains
 	c.AllowURLRevisit = false
 	c.DisallowedDomains = getBannedDomains(config.Crawler.BannedDomains)
-	// from colly's docs: "DisallowedURLFilters sets the list of regular expressions which restricts visiting URLs. If any of the rules
-	// matches to a URL the request will be stopped."
-	c.DisallowedURLFilters = getBannedURLParts()
 	c.IgnoreRobotsTxt = false
 
+	// colly's DisallowedURLFilters has no notion of allow rules, so we check the url patterns ourselves before every request.
+	// the patterns are matched against the url the page is stored under, e.g. https://example.com/t for /t/index.html
+	urlRules := getURLRules(config.Crawler.BannedURLPatterns)
+	c.OnRequest(func(r *colly.Request) {
+		if ok, _ := urlRules.Check(util.NormalizeURL(r.URL.String())); !ok {
+			r.Abort()
+		}
+	})
+
 	delay, _ := time.ParseDuration("200ms")
 	c.Limit(&colly.LimitRule{DomainGlob: "*", Delay: delay, Parallelism: 3})
 
 	boringDomains := getBoringDomains(config.Crawler.BoringDomains)
 	boringWords := getBoringWords(config.Crawler.BoringWords)
 	previewQueries := getPreviewQueries(config.Crawler.PreviewQueries)
+	queryRules := getQueryRules(config.Crawler.QueryParameters)
 	heuristics := getAboutHeuristics(config.Data.Heuristics)
 
+	handleRobots(c)
+
 	// on every a element which has an href attribute, call callback
 	c.OnHTML("a[href]", func(e *colly.HTMLElement) {
 		if e.Response.StatusCode >= 400 || e.Response.StatusCode <= 100 {
 			return
 		}
 
-		link := getLink(e.Attr("href"))
-		if findSuffix(SUFFIXES, link) {
+		// the page, or the link itself, asks that its links are not followed
+		if getRobotsDirectives(e.Request.Ctx).nofollow || find(strings.Fields(strings.ToLower(e.Attr("rel"))), "nofollow") {
 			return
 		}
 
-		link = e.Request.AbsoluteURL(link)
+		link := e.Request.AbsoluteURL(strings.TrimSpace(e.Attr("href")))
 		u, err := url.Parse(link)
-		if err != nil {
+		if len(link) == 0 || err != nil {
+			return
+		}
+		if findSuffix(SUFFIXES, getLink(u.Path)) {
 			return
 		}
+		queryRules.Apply(u)
+		u.Fragment = ""
+		u.RawFragment = ""
+		// the page is fetched, and its link logged, as it was linked—only its query parameters are rewritten. ingest, and
+		// the checks of which pages are crawled, use the normalized url
+		link = u.String()
+		normalized := util.NormalizeURL(link)
 
 		outgoingDomain := u.Hostname()
 		currentDomain := e.Request.URL.Hostname()
 
-		// log which site links to what
-		if !util.Contains(boringWords, link) && !util.Contains(boringDomains, link) {
+		// log which site links to what. the links of a noindex page are followed, but not logged, as that would
+		// make ingest create the page
+		noindex := getRobotsDirectives(e.Request.Ctx).noindex
+		if !noindex && !util.Contains(boringWords, link) && !util.Contains(boringDomains, link) {
+			// the link text follows the link, and describes what the linking page thinks of it
+			anchor := getAnchorText(e)
 			if !find(domains, outgoingDomain) {
-				fmt.Println("non-webring-link", link, e.Request.URL)
+				fmt.Println("non-webring-link", link, anchor, e.Request.URL)
+				outgoing.add(link)
+			} else {
 				// solidarity! someone in the webring linked to someone else in it
-			} else if outgoingDomain != currentDomain && outgoingDomain != initialDomain && currentDomain != initialDomain {
-				fmt.Println("webring-link", link, e.Request.URL)
+				if outgoingDomain != currentDomain && outgoingDomain != initialDomain && currentDomain != initialDomain {
+					fmt.Println("webring-link", link, e.Request.URL)
+				}
+				if len(anchor) > 0 && normalized != util.NormalizeURL(e.Request.URL.String()) {
+					fmt.Println("anchor", link, anchor, e.Request.URL)
+				}
 			}
 		}
 
@@ -353,10 +541,10 @@ func Crawl(config types.Config) {
 		// existing on a shared domain)
 		if pathsite != "" {
 			// make sure we're only crawling descendents of the original path
-			if strings.HasPrefix(link, pathsite) {
+			if strings.HasPrefix(normalized, pathsite) && queued.add(link) {
 				q.AddURL(link)
 			}
-		} else {
+		} else if queued.add(link) {
 			// visits links from AllowedDomains
 			q.AddURL(link)
 		}
@@ -366,4 +554,6 @@ func Crawl(config types.Config) {
 
 	// start scraping
 	q.Run(c)
+
+	discover(config, outgoing.links)
 }
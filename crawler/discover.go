@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gomod.cblgh.org/lieu/types"
+	"gomod.cblgh.org/lieu/util"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/gocolly/colly/v2/queue"
+)
+
+// outgoingLinks collects the unique links pointing outside of the webring while the webring is crawled
+type outgoingLinks struct {
+	sync.Mutex
+	seen  map[string]bool
+	links []string
+}
+
+func (o *outgoingLinks) add(link string) {
+	o.Lock()
+	defer o.Unlock()
+	if o.seen == nil {
+		o.seen = make(map[string]bool)
+	}
+	if !o.seen[link] {
+		o.seen[link] = true
+		o.links = append(o.links, link)
+	}
+}
+
+// discover visits every page the webring links to—once, and without following any of their links—to log their title
+// and description. this lets the outgoing search present the linked pages by their titles rather than their urls.
+func discover(config types.Config, links []string) {
+	c := colly.NewCollector(
+		colly.MaxDepth(1),
+	)
+	if config.General.Proxy != "" {
+		c.SetProxy(config.General.Proxy)
+	}
+
+	q, _ := queue.New(
+		5, /* threads */
+		&queue.InMemoryQueueStorage{MaxSize: 100000},
+	)
+	for _, link := range links {
+		q.AddURL(link)
+	}
+
+	c.UserAgent = "Lieu"
+	c.AllowURLRevisit = false
+	c.DisallowedDomains = getBannedDomains(config.Crawler.BannedDomains)
+	c.IgnoreRobotsTxt = false
+
+	delay, _ := time.ParseDuration("200ms")
+	c.Limit(&colly.LimitRule{DomainGlob: "*", Delay: delay, Parallelism: 3})
+
+	// the page may redirect: remember the url it was linked to with, which is how ingest will know it
+	c.OnRequest(func(r *colly.Request) {
+		r.Ctx.Put("link", r.URL.String())
+	})
+
+	handleRobots(c)
+
+	c.OnHTML("head title", func(e *colly.HTMLElement) {
+		if getRobotsDirectives(e.Request.Ctx).noindex {
+			return
+		}
+		title := util.CleanText(e.Text)
+		if len(title) > 0 && len(title) < 500 {
+			fmt.Println("external-title", title, e.Request.Ctx.Get("link"))
+		}
+	})
+
+	c.OnHTML("meta[name=\"description\"], meta[property=\"og:description\"]", func(e *colly.HTMLElement) {
+		directives := getRobotsDirectives(e.Request.Ctx)
+		if directives.noindex || directives.nosnippet {
+			return
+		}
+		desc := util.CleanText(e.Attr("content"))
+		if len(desc) > 0 && len(desc) < 1500 {
+			fmt.Println("external-desc", desc, e.Request.Ctx.Get("link"))
+		}
+	})
+
+	q.Run(c)
+}
@@ -0,0 +1,278 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gomod.cblgh.org/lieu/util"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+// structuredData is the metadata a page explicitly marks up about itself, using microformats2 or schema.org JSON-LD
+type structuredData struct {
+	Name      string
+	Summary   string
+	Published string
+	Authors   []string
+	Tags      []string
+}
+
+// merge fills the fields of d which are still empty with those of other
+func (d *structuredData) merge(other structuredData) {
+	if len(d.Name) == 0 {
+		d.Name = other.Name
+	}
+	if len(d.Summary) == 0 {
+		d.Summary = other.Summary
+	}
+	if len(d.Published) == 0 {
+		d.Published = other.Published
+	}
+	if len(d.Authors) == 0 {
+		d.Authors = other.Authors
+	}
+	if len(d.Tags) == 0 {
+		d.Tags = other.Tags
+	}
+}
+
+// microformat roots; properties nested inside another root belong to it, and not to the entry being parsed
+const microformatRoots = ".h-entry, .h-cite, .h-card, .h-event, .h-review, .h-product"
+
+// findProperty returns the elements of root with the microformat property class, skipping those of nested microformats
+func findProperty(root *goquery.Selection, class string) *goquery.Selection {
+	return root.Find("." + class).FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return s.ParentsUntilSelection(root).Filter(microformatRoots).Length() == 0
+	})
+}
+
+// see https://microformats.org/wiki/h-entry
+func extractMicroformats(doc *goquery.Selection) structuredData {
+	var data structuredData
+	entry := doc.Find(".h-entry").First()
+	if entry.Length() == 0 {
+		return data
+	}
+
+	data.Name = util.CleanText(findProperty(entry, "p-name").First().Text())
+	data.Summary = util.CleanText(findProperty(entry, "p-summary").First().Text())
+	if len(data.Summary) == 0 {
+		data.Summary = util.CleanText(findProperty(entry, "e-summary").First().Text())
+	}
+
+	published := findProperty(entry, "dt-published").First()
+	if datetime, exists := published.Attr("datetime"); exists {
+		data.Published = util.CleanText(datetime)
+	} else {
+		data.Published = util.CleanText(published.Text())
+	}
+
+	// authors are either plain text, or a nested h-card
+	entry.Find(".p-author").Each(func(_ int, s *goquery.Selection) {
+		if s.ParentsUntilSelection(entry).Filter(microformatRoots).Length() > 0 {
+			return
+		}
+		name := s.Text()
+		if s.HasClass("h-card") {
+			if cardName := findProperty(s, "p-name").First(); cardName.Length() > 0 {
+				name = cardName.Text()
+			}
+		}
+		if name = util.CleanText(name); len(name) > 0 {
+			data.Authors = append(data.Authors, name)
+		}
+	})
+
+	findProperty(entry, "p-category").Each(func(_ int, s *goquery.Selection) {
+		if tag := util.CleanText(s.Text()); len(tag) > 0 {
+			data.Tags = append(data.Tags, tag)
+		}
+	})
+	return data
+}
+
+// the schema.org types describing a page's main content
+var jsonLDTypes = []string{
+	"Article", "BlogPosting", "NewsArticle", "TechArticle", "SocialMediaPosting", "Blog", "WebPage", "AboutPage",
+	"ProfilePage", "CreativeWork", "VisualArtwork", "Photograph", "Recipe", "Review", "Book", "MusicRecording",
+}
+
+// collectJSONLDObjects flattens the arrays and @graph lists of a JSON-LD document into a list of objects
+func collectJSONLDObjects(node interface{}, objects *[]map[string]interface{}) {
+	switch v := node.(type) {
+	case []interface{}:
+		for _, item := range v {
+			collectJSONLDObjects(item, objects)
+		}
+	case map[string]interface{}:
+		*objects = append(*objects, v)
+		if graph, exists := v["@graph"]; exists {
+			collectJSONLDObjects(graph, objects)
+		}
+	}
+}
+
+// jsonLDStrings reads a JSON-LD value which may be a string, an object with a name, or a list of either
+func jsonLDStrings(node interface{}) []string {
+	var values []string
+	switch v := node.(type) {
+	case string:
+		if s := util.CleanText(v); len(s) > 0 {
+			values = append(values, s)
+		}
+	case map[string]interface{}:
+		values = append(values, jsonLDStrings(v["name"])...)
+	case []interface{}:
+		for _, item := range v {
+			values = append(values, jsonLDStrings(item)...)
+		}
+	}
+	return values
+}
+
+func jsonLDString(node interface{}) string {
+	if values := jsonLDStrings(node); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func hasJSONLDType(object map[string]interface{}) bool {
+	for _, t := range jsonLDStrings(object["@type"]) {
+		if find(jsonLDTypes, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// see https://schema.org/CreativeWork
+func extractJSONLD(doc *goquery.Selection) structuredData {
+	var data structuredData
+	doc.Find("script[type=\"application/ld+json\"]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		var document interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &document); err != nil {
+			return true
+		}
+		var objects []map[string]interface{}
+		collectJSONLDObjects(document, &objects)
+		for _, object := range objects {
+			if !hasJSONLDType(object) {
+				continue
+			}
+			var found structuredData
+			found.Name = jsonLDString(object["headline"])
+			if len(found.Name) == 0 {
+				found.Name = jsonLDString(object["name"])
+			}
+			found.Summary = jsonLDString(object["description"])
+			found.Published = jsonLDString(object["datePublished"])
+			found.Authors = jsonLDStrings(object["author"])
+			// keywords are either a list, or a comma separated string
+			for _, keywords := range jsonLDStrings(object["keywords"]) {
+				for _, tag := range strings.Split(keywords, ",") {
+					if tag = strings.TrimSpace(tag); len(tag) > 0 {
+						found.Tags = append(found.Tags, tag)
+					}
+				}
+			}
+			data.merge(found)
+		}
+		return true
+	})
+	return data
+}
+
+// extractAuthors finds the page's authors in <meta name="author">, or failing that, in its rel=author links
+func extractAuthors(doc *goquery.Selection) []string {
+	var authors []string
+	doc.Find("meta[name=\"author\"][content]").Each(func(_ int, s *goquery.Selection) {
+		if author := util.CleanText(s.AttrOr("content", "")); len(author) > 0 {
+			authors = append(authors, author)
+		}
+	})
+	if len(authors) > 0 {
+		return authors
+	}
+	doc.Find("a[rel~=\"author\"]").Each(func(_ int, s *goquery.Selection) {
+		if author := util.CleanText(s.Text()); len(author) > 0 {
+			authors = append(authors, author)
+		}
+	})
+	return authors
+}
+
+// extractTags finds the tags of a page in its rel=tag links and article:tag metadata
+func extractTags(doc *goquery.Selection) []string {
+	var tags []string
+	doc.Find("a[rel~=\"tag\"]").Each(func(_ int, s *goquery.Selection) {
+		if tag := util.CleanText(s.Text()); len(tag) > 0 {
+			tags = append(tags, tag)
+		}
+	})
+	doc.Find("meta[property=\"article:tag\"][content]").Each(func(_ int, s *goquery.Selection) {
+		if tag := util.CleanText(s.AttrOr("content", "")); len(tag) > 0 {
+			tags = append(tags, tag)
+		}
+	})
+	return tags
+}
+
+// collectPerson logs who the page says is behind the site: the name and nickname of its representative h-card (the
+// one that isn't a part of a post), and its fediverse handle. see https://microformats.org/wiki/h-card
+func collectPerson(e *colly.HTMLElement) {
+	card := e.DOM.Find(".h-card").FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return s.ParentsFiltered(microformatRoots).Length() == 0
+	}).First()
+	if card.Length() > 0 {
+		name := util.CleanText(findProperty(card, "p-name").First().Text())
+		// the name of a card without properties is its text, e.g. <a class="h-card" href="/">Lupin</a>
+		if len(name) == 0 && card.Find("[class*=\"p-\"], [class*=\"u-\"]").Length() == 0 {
+			name = util.CleanText(card.Text())
+		}
+		if len(name) > 0 && len(name) < 200 {
+			fmt.Println("person-name", name, e.Request.URL)
+		}
+		findProperty(card, "p-nickname").Each(func(_ int, s *goquery.Selection) {
+			if handle := util.CleanText(s.Text()); len(handle) > 0 && len(handle) < 200 {
+				fmt.Println("person-handle", handle, e.Request.URL)
+			}
+		})
+	}
+	if handle := util.CleanText(e.DOM.Find("meta[name=\"fediverse:creator\"]").AttrOr("content", "")); len(handle) > 0 && len(handle) < 200 {
+		fmt.Println("person-handle", handle, e.Request.URL)
+	}
+}
+
+// collectStructuredData logs the page's microformats2 and JSON-LD metadata. microformats win over JSON-LD, as they
+// are marked up by hand more often than not.
+func collectStructuredData(e *colly.HTMLElement) {
+	data := extractMicroformats(e.DOM)
+	data.merge(extractJSONLD(e.DOM))
+	data.merge(structuredData{Authors: extractAuthors(e.DOM)})
+	// unlike the other fields, tags from every source are kept
+	data.Tags = append(data.Tags, extractTags(e.DOM)...)
+
+	if len(data.Name) > 0 && len(data.Name) < 500 {
+		fmt.Println("meta-name", data.Name, e.Request.URL)
+	}
+	if len(data.Summary) > 20 && len(data.Summary) < 1500 {
+		fmt.Println("meta-summary", data.Summary, e.Request.URL)
+	}
+	if len(data.Published) > 0 && len(data.Published) < 100 {
+		fmt.Println("meta-published", data.Published, e.Request.URL)
+	}
+	for _, author := range util.DeduplicateSlice(data.Authors) {
+		if len(author) < 200 {
+			fmt.Println("meta-author", author, e.Request.URL)
+		}
+	}
+	for _, tag := range util.DeduplicateSlice(data.Tags) {
+		if len(tag) < 100 && len(util.NormalizeTag(tag)) > 0 {
+			fmt.Println("meta-tag", tag, e.Request.URL)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"gomod.cblgh.org/lieu/types"
+	"gomod.cblgh.org/lieu/util"
+)
+
+// used if the config's crawler.bannedURLPatterns file is missing or empty. they prevent us from crawling or logging
+// data from e.g. voluminous pages like accidentally ending up in a repo, tag categories, pages with only images
+var defaultBannedURLPatterns = []string{
+	`https?:\/\/\S+\/gallery\/\S+`,
+	`https?:\/\/\S+\/repo\/\S+`,
+	`https?:\/\/\S+\/repos\/\S+`,
+	`https?:\/\/\S+\/repository\/\S+`,
+	`https?:\/\/\S+\/repositories\/\S+`,
+	`https?:\/\/\S+\/tags\/\S+`,
+	`https?:\/\/\S+\/tag\/\S+`,
+	`https?:\/\/\S+\/tagged\/\S+`,
+	`https?:\/\/\S+\/t\/\S+`,
+	`https?:\/\/\S+\/commit\/\S+`,
+	`https?:\/\/\S+\/commits\/\S+`,
+}
+
+type urlRule struct {
+	pattern *regexp.Regexp
+	source  string // the line the rule was read from, used when explaining a decision
+	allow   bool
+}
+
+// URLRules decides whether a url may be crawled. rules are read linewise from the banned url patterns file:
+//
+//	# comment
+//	https?://\S+/commits?/\S+     (a url matching this regex is banned)
+//	!https?://example\.com/tags/  (a url matching this regex is allowed, even if a ban rule matches it)
+//
+// allow rules always win over ban rules, regardless of the order they appear in.
+type URLRules struct {
+	banned  []urlRule
+	allowed []urlRule
+}
+
+func parseURLRules(lines []string) (URLRules, error) {
+	var rules URLRules
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		allow := strings.HasPrefix(line, "!")
+		expr := strings.TrimSpace(strings.TrimPrefix(line, "!"))
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return rules, fmt.Errorf("invalid url pattern on line %d: %q (%w)", i+1, expr, err)
+		}
+		rule := urlRule{pattern: pattern, source: line, allow: allow}
+		if allow {
+			rules.allowed = append(rules.allowed, rule)
+		} else {
+			rules.banned = append(rules.banned, rule)
+		}
+	}
+	return rules, nil
+}
+
+func getURLRules(path string) URLRules {
+	lines := util.ReadList(path, "\n")
+	if len(lines) == 0 {
+		lines = defaultBannedURLPatterns
+	}
+	rules, err := parseURLRules(lines)
+	util.Check(err)
+	return rules
+}
+
+// Check returns whether link may be crawled, and the rule responsible for the decision. the returned rule is empty if
+// no rule matched link.
+func (r URLRules) Check(link string) (bool, string) {
+	for _, rule := range r.allowed {
+		if rule.pattern.MatchString(link) {
+			return true, rule.source
+		}
+	}
+	for _, rule := range r.banned {
+		if rule.pattern.MatchString(link) {
+			return false, rule.source
+		}
+	}
+	return true, ""
+}
+
+// QueryRules decide which query parameters survive when a url is crawled. rules are read linewise from the query
+// parameters file:
+//
+//	# comment
+//	forum.example.com thread page  (keep the thread and page parameters on forum.example.com)
+//	* page                         (keep the page parameter on every domain)
+//	-utm_*                         (always strip parameters starting with utm_, even if they were allowed)
+//
+// parameters that are not allowed for a url's domain are stripped, and the remaining ones are sorted so that the same
+// page always gets the same url.
+type QueryRules struct {
+	allowed  map[string][]string
+	tracking []string
+}
+
+func parseQueryRules(lines []string) QueryRules {
+	rules := QueryRules{allowed: make(map[string][]string)}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "-") {
+			for _, param := range fields {
+				rules.tracking = append(rules.tracking, strings.ToLower(strings.TrimPrefix(param, "-")))
+			}
+			continue
+		}
+		domain := strings.TrimPrefix(strings.ToLower(fields[0]), "www.")
+		for _, param := range fields[1:] {
+			rules.allowed[domain] = append(rules.allowed[domain], strings.ToLower(param))
+		}
+	}
+	return rules
+}
+
+// if the query parameters file is missing or empty, every query parameter is stripped
+func getQueryRules(path string) QueryRules {
+	return parseQueryRules(util.ReadList(path, "\n"))
+}
+
+// matchParam matches a parameter name against a pattern, which may end with * to match any parameter with that prefix
+func matchParam(pattern, param string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(param, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == param
+}
+
+func (r QueryRules) keep(domain, param string) bool {
+	param = strings.ToLower(param)
+	for _, pattern := range r.tracking {
+		if matchParam(pattern, param) {
+			return false
+		}
+	}
+	for _, d := range []string{strings.TrimPrefix(strings.ToLower(domain), "www."), "*"} {
+		for _, pattern := range r.allowed[d] {
+			if matchParam(pattern, param) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Apply strips the query parameters of u which should not be kept, and sorts the rest
+func (r QueryRules) Apply(u *url.URL) {
+	if u.RawQuery == "" {
+		return
+	}
+	params := u.Query()
+	for param := range params {
+		if !r.keep(u.Hostname(), param) {
+			params.Del(param)
+		}
+	}
+	// Encode sorts by key
+	u.RawQuery = params.Encode()
+}
+
+// CheckURL explains whether the crawler would crawl link, considering the webring, banned domains, banned suffixes and
+// the url patterns files. it also shows how the query parameters rules rewrite link.
+func CheckURL(config types.Config, link string) {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		fmt.Printf("lieu: %q is not a valid url (%s)\n", link, err)
+		return
+	}
+	getQueryRules(config.Crawler.QueryParameters).Apply(u)
+	if u.String() != link {
+		fmt.Printf("crawled as %s\n", u.String())
+	}
+	// the crawler tells pages apart, and checks them, by their normalized urls
+	link = util.NormalizeURL(u.String())
+	if link != u.String() {
+		fmt.Printf("checked and stored as %s\n", link)
+	}
+
+	if find(getBannedDomains(config.Crawler.BannedDomains), u.Hostname()) {
+		fmt.Printf("blocked: domain %q is listed in %s\n", u.Hostname(), config.Crawler.BannedDomains)
+		return
+	}
+	domains, pathsites := getDomains(getWebringLinks(config.Crawler.Webring))
+	if !find(domains, u.Hostname()) {
+		fmt.Printf("blocked: domain %q is not listed in %s\n", u.Hostname(), config.Crawler.Webring)
+		return
+	}
+	// the same check as the crawler's: a site with a path only has the pages below its path crawled
+	for _, pathsite := range pathsites {
+		if strings.Contains(pathsite, u.Hostname()) {
+			if !strings.HasPrefix(link, pathsite) {
+				fmt.Printf("blocked: only the pages below %s are crawled, as listed in %s\n", pathsite, config.Crawler.Webring)
+				return
+			}
+			break
+		}
+	}
+	for _, suffix := range getBannedSuffixes(config.Crawler.BannedSuffixes) {
+		if findSuffix([]string{suffix}, getLink(link)) {
+			fmt.Printf("blocked: suffix %q is listed in %s\n", suffix, config.Crawler.BannedSuffixes)
+			return
+		}
+	}
+
+	source := config.Crawler.BannedURLPatterns
+	if len(util.ReadList(source, "\n")) == 0 {
+		source = "the default url patterns"
+	}
+	ok, rule := getURLRules(config.Crawler.BannedURLPatterns).Check(link)
+	switch {
+	case rule == "":
+		fmt.Println("allowed: no rule matched")
+	case ok:
+		fmt.Printf("allowed: matched allow rule `%s` in %s\n", rule, source)
+	default:
+		fmt.Printf("blocked: matched rule `%s` in %s\n", rule, source)
+	}
+}
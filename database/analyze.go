@@ -0,0 +1,54 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"gomod.cblgh.org/lieu/util"
+)
+
+// Analyze gathers the statistics sqlite's query planner uses to choose between the indexes of a table
+func Analyze(db *sql.DB) {
+	for _, query := range []string{"ANALYZE", "PRAGMA optimize"} {
+		_, err := db.Exec(query)
+		util.Check(err)
+	}
+}
+
+// SampleWords returns the words found on the most pages, which are the slowest to search for
+func SampleWords(db *sql.DB, limit int) []string {
+	rows, err := db.Query("SELECT word FROM inv_index GROUP BY word ORDER BY COUNT(DISTINCT url) DESC LIMIT ?", limit)
+	util.Check(err)
+	defer rows.Close()
+
+	var words []string
+	var word string
+	for rows.Next() {
+		err = rows.Scan(&word)
+		util.Check(err)
+		words = append(words, word)
+	}
+	return words
+}
+
+// ExplainSearch describes how sqlite plans to search for the words, one step per line. the steps a step consists of
+// are indented below it.
+func ExplainSearch(db *sql.DB, words []string) []string {
+	query, args := searchWordsQuery(words, emptyStringArray, true, emptyStringArray, emptyStringArray, emptyStringArray, emptyStringArray, emptyStringArray)
+	rows, err := db.Query("EXPLAIN QUERY PLAN "+query, args...)
+	util.Check(err)
+	defer rows.Close()
+
+	var plan []string
+	depth := make(map[int]int)
+	var id, parent, unused int
+	var detail string
+	for rows.Next() {
+		err = rows.Scan(&id, &parent, &unused, &detail)
+		util.Check(err)
+		depth[id] = depth[parent] + 1
+		plan = append(plan, fmt.Sprintf("%s%s", strings.Repeat("  ", depth[id]-1), detail))
+	}
+	return plan
+}
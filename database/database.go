@@ -16,8 +16,10 @@ import (
 	"fmt"
 	"html/template"
 	"log"
+	"math"
 	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 
 	"gomod.cblgh.org/lieu/types"
@@ -29,6 +31,14 @@ import (
 var languageCodeSanityRegex = regexp.MustCompile("^[a-zA-Z\\-0-9]+$")
 
 func InitDB(filepath string) *sql.DB {
+	db := InitBulkDB(filepath)
+	CreateIndexes(db)
+	return db
+}
+
+// InitBulkDB creates the tables without their indexes, which are faster to build once the tables have been filled
+// than to keep up to date while filling them. CreateIndexes builds them.
+func InitBulkDB(filepath string) *sql.DB {
 	db, err := sql.Open("sqlite3", filepath)
 	if err != nil {
 		log.Fatalln(err)
@@ -40,6 +50,22 @@ func InitDB(filepath string) *sql.DB {
 	return db
 }
 
+// CreateIndexes creates the indexes of the tables, if they don't exist yet
+func CreateIndexes(db *sql.DB) {
+	queries := []string{
+		// searches look up words, summing their scores per page; the index covers all of the columns they use
+		`CREATE INDEX IF NOT EXISTS inv_index_word ON inv_index (word, url, score)`,
+		// searches filtering the pages, e.g. by their site, can instead look up the words of the matching pages
+		`CREATE INDEX IF NOT EXISTS inv_index_url ON inv_index (url)`,
+	}
+
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			log.Fatalln(fmt.Errorf("failed to execute %s (%w)", query, err))
+		}
+	}
+}
+
 func createTables(db *sql.DB) {
 	// create the table if it doesn't exist
 	queries := []string{
@@ -62,7 +88,15 @@ func createTables(db *sql.DB) {
         title TEXT,
         about TEXT,
         lang TEXT,
+        declared_lang TEXT,
+        detected_lang TEXT,
+        lang_confidence REAL,
+        published TEXT,
+        author TEXT,
         domain TEXT NOT NULL,
+        fingerprint INTEGER,
+        duplicate_of TEXT,
+        similar INTEGER NOT NULL DEFAULT 0,
         FOREIGN KEY(domain) REFERENCES domains(domain)
     );
     `,
@@ -71,7 +105,8 @@ func createTables(db *sql.DB) {
         id INTEGER PRIMARY KEY AUTOINCREMENT,
         url TEXT NOT NULL UNIQUE,
         domain TEXT NOT NULL,
-        title TEXT
+        title TEXT,
+        about TEXT
     );
     `,
 		`
@@ -79,12 +114,58 @@ func createTables(db *sql.DB) {
         word TEXT NOT NULL,
         score INTEGER NOT NULL,
         url TEXT NOT NULL,
+        field TEXT NOT NULL DEFAULT 'body',
+        FOREIGN KEY(url) REFERENCES pages(url)
+    )`,
+
+		`
+    CREATE TABLE IF NOT EXISTS anchors (
+        url TEXT NOT NULL,
+        source TEXT NOT NULL,
+        domain TEXT NOT NULL,
+        anchor TEXT NOT NULL,
         FOREIGN KEY(url) REFERENCES pages(url)
     )`,
 
-		`CREATE VIRTUAL TABLE IF NOT EXISTS external_links USING fts5 (url, tokenize="trigram")`,
+		`
+    CREATE TABLE IF NOT EXISTS external_link_sources (
+        url TEXT NOT NULL,
+        source TEXT NOT NULL,
+        domain TEXT NOT NULL,
+        anchor TEXT
+    )`,
+
+		`
+    CREATE TABLE IF NOT EXISTS word_forms (
+        word TEXT NOT NULL UNIQUE,
+        form TEXT NOT NULL
+    )`,
+
+		`
+    CREATE TABLE IF NOT EXISTS tags (
+        url TEXT NOT NULL,
+        tag TEXT NOT NULL,
+        UNIQUE(url, tag),
+        FOREIGN KEY(url) REFERENCES pages(url)
+    )`,
+
+		`
+    CREATE TABLE IF NOT EXISTS people (
+        domain TEXT NOT NULL,
+        name TEXT NOT NULL,
+        kind TEXT NOT NULL,
+        UNIQUE(domain, name, kind),
+        FOREIGN KEY(domain) REFERENCES domains(domain)
+    )`,
+
+		`CREATE VIRTUAL TABLE IF NOT EXISTS external_links USING fts5 (url, title, anchors, tokenize="trigram")`,
 
-		`CREATE VIRTUAL TABLE IF NOT EXISTS big_search USING fts5 (text, url, tokenize="porter")`,
+		`CREATE VIRTUAL TABLE IF NOT EXISTS images USING fts5 (alt, src UNINDEXED, url UNINDEXED, tokenize="porter")`,
+
+		// segmented holds the text split into bigrams, if it contains any chinese, japanese or korean text; see fulltextQuery
+		`CREATE VIRTUAL TABLE IF NOT EXISTS big_search USING fts5 (text, url, fingerprint UNINDEXED, segmented, tokenize="porter")`,
+
+		`CREATE VIRTUAL TABLE IF NOT EXISTS headings USING fts5 (text, url UNINDEXED, segmented, tokenize="porter")`,
 	}
 
 	for _, query := range queries {
@@ -106,21 +187,49 @@ query params:
 
 var emptyStringArray = []string{}
 
+// the number of pages a search for words results in
+const PageLimit = 15
+
+// SearchWords finds more pages than it results in, so that they can be picked among, e.g. to not let one site take up
+// all of the results
+const candidateLimit = 4 * PageLimit
+
+func firstPages(pages []types.PageData) []types.PageData {
+	if len(pages) > PageLimit {
+		return pages[:PageLimit]
+	}
+	return pages
+}
+
 func SearchWordsByScore(db *sql.DB, words []string) []types.PageData {
-	return SearchWords(db, words, true, emptyStringArray, emptyStringArray, emptyStringArray)
+	return firstPages(SearchWords(db, words, emptyStringArray, true, emptyStringArray, emptyStringArray, emptyStringArray, emptyStringArray, emptyStringArray))
 }
 
 func SearchWordsBySite(db *sql.DB, words []string, domain string) []types.PageData {
 	// search words by site is same as search words by score, but adds a domain condition
-	return SearchWords(db, words, true, []string{domain}, emptyStringArray, emptyStringArray)
+	return firstPages(SearchWords(db, words, emptyStringArray, true, []string{domain}, emptyStringArray, emptyStringArray, emptyStringArray, emptyStringArray))
 }
 
 func SearchWordsByCount(db *sql.DB, words []string) []types.PageData {
-	return SearchWords(db, words, false, emptyStringArray, emptyStringArray, emptyStringArray)
+	return firstPages(SearchWords(db, words, emptyStringArray, false, emptyStringArray, emptyStringArray, emptyStringArray, emptyStringArray, emptyStringArray))
 }
 
+// FulltextSearchWords searches the links leading outside of the webring, matching the phrase against their urls, titles
+// and anchor texts. the matches are ranked by how well they match—anchor texts and titles weigh the most—and by how
+// many of the webring's domains link to them.
 func FulltextSearchWords(db *sql.DB, phrase string) []types.PageData {
-	query := fmt.Sprintf(`SELECT url from external_links WHERE url MATCH ? GROUP BY url ORDER BY RANDOM() LIMIT 30`)
+	query := `
+	WITH matches AS (
+		SELECT url, bm25(external_links, 1.0, 5.0, 10.0) AS rank
+		FROM external_links WHERE external_links MATCH ?
+		ORDER BY rank LIMIT 300
+	)
+	SELECT m.url, m.rank, COALESCE(ep.title, ''), COALESCE(ep.about, ''), COUNT(DISTINCT s.domain), GROUP_CONCAT(DISTINCT s.domain)
+	FROM matches m
+	INNER JOIN external_link_sources s ON s.url = m.url
+	LEFT JOIN external_pages ep ON ep.url = m.url
+	GROUP BY m.url
+	`
 
 	stmt, err := db.Prepare(query)
 	util.Check(err)
@@ -130,21 +239,54 @@ func FulltextSearchWords(db *sql.DB, phrase string) []types.PageData {
 	util.Check(err)
 	defer rows.Close()
 
-	var pageData types.PageData
-	pages := make([]types.PageData, 0, 30)
+	type rankedPage struct {
+		page  types.PageData
+		score float64
+	}
+	var ranked []rankedPage
 	for rows.Next() {
-		if err := rows.Scan(&pageData.URL); err != nil {
+		var pageData types.PageData
+		var rank float64
+		var linkedFrom string
+		if err := rows.Scan(&pageData.URL, &rank, &pageData.Title, &pageData.About, &pageData.LinkCount, &linkedFrom); err != nil {
 			log.Fatalln(err)
 		}
-		pageData.Title = pageData.URL
-		pages = append(pages, pageData)
+		pageData.LinkedFrom = strings.Split(linkedFrom, ",")
+		// bm25 ranks better matches with lower (negative) numbers
+		score := -rank * (1 + math.Log(float64(pageData.LinkCount)))
+		ranked = append(ranked, rankedPage{page: pageData, score: score})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+
+	pages := make([]types.PageData, 0, 30)
+	for i := 0; i < len(ranked) && i < 30; i++ {
+		pages = append(pages, ranked[i].page)
 	}
 	return pages
 }
 
+const (
+	// the number of pages the paragraph search returns
+	maxParagraphPages = 30
+	// the number of matching paragraphs shown for each page
+	maxParagraphsPerPage = 3
+)
+
+// fulltextQuery restricts a fulltext query of the paragraphs or headings to their text. the unicode61 tokenizer doesn't
+// split chinese, japanese and korean text into words, so such text is matched by its bigrams, like the words of pages
+// are.
+func fulltextQuery(phrase string) string {
+	if segmented := util.SegmentCJKText(phrase); segmented != "" {
+		phrase = segmented
+	}
+	return "{text segmented} : (" + phrase + ")"
+}
+
 func FulltextSearchWholeParagraphs(db *sql.DB, phrase string, domain []string, nodomain []string) []types.PageData {
 	var args []interface{}
-	args = append(args, phrase)
+	args = append(args, fulltextQuery(phrase))
 
 	domains := []string{"1"}
 	if len(domain) > 0 && domain[0] != "" {
@@ -165,11 +307,11 @@ func FulltextSearchWholeParagraphs(db *sql.DB, phrase string, domain []string, n
 	}
 
 	query := fmt.Sprintf(`
-	SELECT bs.text, p.about, HIGHLIGHT(big_search, 0, '<strong>', '</strong>'), bs.url FROM big_search bs INNER JOIN pages p ON bs.url = p.url 
-	WHERE bs.text MATCH ? 
+	SELECT bs.text, p.about, HIGHLIGHT(big_search, 0, '<strong>', '</strong>'), bs.url, bs.fingerprint, p.similar FROM big_search bs INNER JOIN pages p ON bs.url = p.url 
+	WHERE big_search MATCH ? 
   AND (%s)
   AND (%s)
-	ORDER BY bs.rank LIMIT 30
+	ORDER BY bs.rank LIMIT 100
 	`, strings.Join(domains, " OR "), strings.Join(nodomains, " AND "))
 
 	// select word, url from inv_index where url = (select distinct url from inv_index limit 100);
@@ -188,52 +330,169 @@ func FulltextSearchWholeParagraphs(db *sql.DB, phrase string, domain []string, n
 	util.Check(err)
 	defer rows.Close()
 
-	var pageData types.PageData
+	// the pages are ranked by their best matching paragraph, which is the first one found as the rows are ordered by rank
 	var pages []types.PageData
-	// `duplicates` keeps track of whether the same text has been returned already -> deduplicates search results.
+	index := make(map[string]int)
+	// `fingerprints` keeps track of whether the same, or nearly the same, text has been returned already -> deduplicates
+	// search results.
 	//
 	// rationale: for the dataset i am testing this on (merveilles forum) the links to the same thread can change, and so
 	// it's more useful to track dupes on a per paragraph basis than per-url.
-	duplicates := make(map[string]bool)
+	var fingerprints []uint64
+	// paragraphs too short to be fingerprinted (their fingerprint is 0) are only deduplicated if their text is the same
+	seen := make(map[string]bool)
+	var pageData types.PageData
 	var paragraphMatch string
 	var unadornedParagraphMatch string
+	var fingerprint int64
 	for rows.Next() {
-		if err := rows.Scan(&unadornedParagraphMatch, &pageData.About, &paragraphMatch, &pageData.URL); err != nil {
+		if err := rows.Scan(&unadornedParagraphMatch, &pageData.About, &paragraphMatch, &pageData.URL, &fingerprint, &pageData.Similar); err != nil {
 			log.Fatalln(err)
 		}
-		if _, exists := duplicates[paragraphMatch]; !exists {
-			pageData.About = pageData.About
-			// both About and the fts paragraph contain the same, null the about paragraph
-			// note: the crawled data represented by `unadornedParagarphMatch` has been run through bluemonday's strict
-			// santiization. this means a lot of html escapes are present in the text. in order to do a fair comparison, we
-			// need to run the same sanitzation on pageData.About for comparing purposes.
-			//
-			// secondary note: pageData.About *does not* need to be sanitize - it is not rendered using the `unsafe`
-			// template.HTML* type; golang's templates will autoescape its content as needed.
-			if strings.EqualFold(unadornedParagraphMatch, util.CleanTextStrict(pageData.About)) {
-				pageData.About = ""
+		if fingerprint == 0 && seen[unadornedParagraphMatch] {
+			continue
+		}
+		if fingerprint != 0 && isNearDuplicateOfAny(fingerprints, uint64(fingerprint)) {
+			continue
+		}
+		i, exists := index[pageData.URL]
+		if !exists {
+			if len(pages) >= maxParagraphPages {
+				continue
 			}
-			// TODO (2024-10-10): surface search syntax legend (details/summary dropdown with pos absolute?) displaying
-			// tricks for links and paragraph search respectively
-
-			// TODO (2024-10-10): convert ParagraphResult to be []template.HTML and collect paragraphs per unique url.
-			// if a len(slice) > 1, render in template as <ul><li>..</li></ul> for all the paragraph matches (but not the pageData.About)
-			// example https://i.cblgh.org/2024-10/11788Ohq.png
-
-			// IDEA (2024-10-11): augment "links search" by using the results returned by it, to then perform a FTS search
-			// `where url = ? OR url = ? ..." for each url returned by the links search results.
-			//
-			// this offers a greater precision (maybe?) using the initial weighted ranking, and then gets us depth of results
-			// by running a constrained fts
-			pageData.URL = pageData.URL
-			pageData.ParagraphResult = template.HTML(paragraphMatch)
+			pageData.ParagraphResults = nil
 			pages = append(pages, pageData)
-			duplicates[paragraphMatch] = true
+			i = len(pages) - 1
+			index[pageData.URL] = i
+		}
+		page := &pages[i]
+		if len(page.ParagraphResults) >= maxParagraphsPerPage {
+			continue
+		}
+		// both About and the fts paragraph contain the same, null the about paragraph
+		// note: the crawled data represented by `unadornedParagarphMatch` has been run through bluemonday's strict
+		// santiization. this means a lot of html escapes are present in the text. in order to do a fair comparison, we
+		// need to run the same sanitzation on pageData.About for comparing purposes.
+		//
+		// secondary note: pageData.About *does not* need to be sanitize - it is not rendered using the `unsafe`
+		// template.HTML* type; golang's templates will autoescape its content as needed.
+		if strings.EqualFold(unadornedParagraphMatch, util.CleanTextStrict(page.About)) {
+			page.About = ""
+		}
+		// TODO (2024-10-10): surface search syntax legend (details/summary dropdown with pos absolute?) displaying
+		// tricks for links and paragraph search respectively
+		page.ParagraphResults = append(page.ParagraphResults, template.HTML(paragraphMatch))
+		if fingerprint == 0 {
+			seen[unadornedParagraphMatch] = true
+		} else {
+			fingerprints = append(fingerprints, uint64(fingerprint))
 		}
 	}
 	return pages
 }
 
+// GetSnippets finds the paragraph or heading of each page best matching the phrase, with the matches highlighted.
+// pages without any matching text are left out.
+func GetSnippets(db *sql.DB, phrase string, urls []string) map[string]template.HTML {
+	snippets := make(map[string]template.HTML)
+	if len(urls) == 0 {
+		return snippets
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(urls)), ", ")
+	var args []interface{}
+	args = append(args, fulltextQuery(phrase))
+	for _, u := range urls {
+		args = append(args, u)
+	}
+	args = append(args, fulltextQuery(phrase))
+	for _, u := range urls {
+		args = append(args, u)
+	}
+
+	// the text of both tables has been sanitized when crawled, and can be rendered as html
+	query := fmt.Sprintf(`
+	SELECT url, snippet FROM (
+		SELECT url, SNIPPET(big_search, 0, '<strong>', '</strong>', '…', 40) AS snippet, rank FROM big_search
+		WHERE big_search MATCH ? AND url IN (%s)
+		UNION ALL
+		SELECT url, HIGHLIGHT(headings, 0, '<strong>', '</strong>') AS snippet, rank FROM headings
+		WHERE headings MATCH ? AND url IN (%s)
+	) ORDER BY rank`, placeholders, placeholders)
+
+	rows, err := db.Query(query, args...)
+	util.Check(err)
+	defer rows.Close()
+
+	var pageurl, snippet string
+	for rows.Next() {
+		err = rows.Scan(&pageurl, &snippet)
+		util.Check(err)
+		if _, exists := snippets[pageurl]; !exists {
+			snippets[pageurl] = template.HTML(snippet)
+		}
+	}
+	return snippets
+}
+
+func isNearDuplicateOfAny(fingerprints []uint64, fingerprint uint64) bool {
+	for _, f := range fingerprints {
+		if util.IsNearDuplicate(f, fingerprint) {
+			return true
+		}
+	}
+	return false
+}
+
+func FulltextSearchImages(db *sql.DB, phrase string, domain []string, nodomain []string) []types.Image {
+	var args []interface{}
+	args = append(args, phrase)
+
+	domains := []string{"1"}
+	if len(domain) > 0 && domain[0] != "" {
+		domains = make([]string, 0) // we've got at least one domain! clear domains default
+		for _, d := range domain {
+			domains = append(domains, "p.domain = ?")
+			args = append(args, d)
+		}
+	}
+
+	nodomains := []string{"1"}
+	if len(nodomain) > 0 && nodomain[0] != "" {
+		nodomains = make([]string, 0)
+		for _, d := range nodomain {
+			nodomains = append(nodomains, "p.domain != ?")
+			args = append(args, d)
+		}
+	}
+
+	query := fmt.Sprintf(`
+	SELECT i.src, i.url, i.alt FROM images i INNER JOIN pages p ON i.url = p.url
+	WHERE i.alt MATCH ?
+	AND (%s)
+	AND (%s)
+	GROUP BY i.src
+	ORDER BY i.rank LIMIT 60
+	`, strings.Join(domains, " OR "), strings.Join(nodomains, " AND "))
+
+	stmt, err := db.Prepare(query)
+	util.Check(err)
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+	util.Check(err)
+	defer rows.Close()
+
+	var image types.Image
+	images := make([]types.Image, 0, 60)
+	for rows.Next() {
+		if err := rows.Scan(&image.Src, &image.URL, &image.Alt); err != nil {
+			log.Fatalln(err)
+		}
+		images = append(images, image)
+	}
+	return images
+}
+
 func UpdateCrawlDate(db *sql.DB, date string) {
 	stmt := `INSERT OR IGNORE INTO stats(last_crawl) VALUES (?)`
 	_, err := db.Exec(stmt, date)
@@ -325,7 +584,39 @@ func countQuery(db *sql.DB, table string) int {
 	return count
 }
 
-func SearchWords(db *sql.DB, words []string, searchByScore bool, domain []string, nodomain []string, language []string) []types.PageData {
+// matches of a search term's synonyms count for this much of a match of the term itself
+const synonymWeight = 0.5
+
+// SearchWords finds the pages best matching the words, returning up to candidateLimit of them
+func SearchWords(db *sql.DB, words []string, synonyms []string, searchByScore bool, domain []string, nodomain []string, language []string, author []string, tag []string) []types.PageData {
+	query, args := searchWordsQuery(words, synonyms, searchByScore, domain, nodomain, language, author, tag)
+
+	stmt, err := db.Prepare(query)
+	util.Check(err)
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+	util.Check(err)
+	defer rows.Close()
+
+	var pageData types.PageData
+	pages := make([]types.PageData, 0, candidateLimit)
+	for rows.Next() {
+		var pageTags string
+		if err := rows.Scan(&pageData.URL, &pageData.About, &pageData.Title, &pageData.Similar, &pageData.Author, &pageTags); err != nil {
+			log.Fatalln(err)
+		}
+		pageData.Tags = nil
+		if len(pageTags) > 0 {
+			pageData.Tags = strings.Split(pageTags, ",")
+		}
+		pages = append(pages, pageData)
+	}
+	return pages
+}
+
+// searchWordsQuery builds the query of SearchWords, and its arguments
+func searchWordsQuery(words []string, synonyms []string, searchByScore bool, domain []string, nodomain []string, language []string, author []string, tag []string) (string, []interface{}) {
 	var args []interface{}
 
 	wordlist := []string{"1"}
@@ -337,6 +628,24 @@ func SearchWords(db *sql.DB, words []string, searchByScore bool, domain []string
 		}
 	}
 
+	// the synonyms of the search terms, except those which are search terms themselves
+	var synonymArgs []interface{}
+	if len(words) > 0 && words[0] != "" {
+		searched := make(map[string]bool)
+		for _, word := range words {
+			searched[strings.ToLower(word)] = true
+		}
+		for _, synonym := range synonyms {
+			synonym = strings.ToLower(synonym)
+			if !searched[synonym] {
+				searched[synonym] = true
+				wordlist = append(wordlist, "word = ?")
+				args = append(args, synonym)
+				synonymArgs = append(synonymArgs, synonym)
+			}
+		}
+	}
+
 	// the domains conditional defaults to just 'true' i.e. no domain condition
 	domains := []string{"1"}
 	if len(domain) > 0 && domain[0] != "" {
@@ -369,41 +678,149 @@ func SearchWords(db *sql.DB, words []string, searchByScore bool, domain []string
 		}
 	}
 
+	// authors match if their name contains the searched for name, e.g. author:ada matches "Ada Lovelace"
+	authors := []string{"1"}
+	if len(author) > 0 && author[0] != "" {
+		authors = make([]string, 0)
+		for _, a := range author {
+			authors = append(authors, "p.author LIKE ?")
+			args = append(args, "%"+a+"%")
+		}
+	}
+
+	tags := []string{"1"}
+	if len(tag) > 0 && tag[0] != "" {
+		tags = make([]string, 0)
+		for _, t := range tag {
+			tags = append(tags, "p.url IN (SELECT url FROM tags WHERE tag = ?)")
+			args = append(args, util.NormalizeTag(t))
+		}
+	}
+
 	orderType := "SUM(score)"
+	if len(synonymArgs) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(synonymArgs)), ", ")
+		orderType = fmt.Sprintf("SUM(score * CASE WHEN word IN (%s) THEN %f ELSE 1 END)", placeholders, synonymWeight)
+	}
 	if !searchByScore {
 		orderType = "COUNT(*)"
 	}
 
 	query := fmt.Sprintf(`
-    SELECT p.url, p.about, p.title 
+    SELECT p.url, p.about, p.title, p.similar, COALESCE(p.author, ''),
+        COALESCE((SELECT GROUP_CONCAT(t.tag) FROM tags t WHERE t.url = p.url), '')
     FROM inv_index inv INNER JOIN pages p ON inv.url = p.url 
-    WHERE (%s)
+    WHERE p.duplicate_of IS NULL
+    AND (%s)
+    AND (%s)
+    AND (%s)
     AND (%s)
     AND (%s)
     AND (%s)
     GROUP BY inv.url 
     ORDER BY %s
     DESC
-    LIMIT 15
-    `, strings.Join(wordlist, " OR "), strings.Join(domains, " OR "), strings.Join(nodomains, " AND "), strings.Join(languages, " OR "), orderType)
+    LIMIT %d
+    `, strings.Join(wordlist, " OR "), strings.Join(domains, " OR "), strings.Join(nodomains, " AND "), strings.Join(languages, " OR "), strings.Join(authors, " OR "), strings.Join(tags, " AND "), orderType, candidateLimit)
+	if searchByScore {
+		args = append(args, synonymArgs...)
+	}
+	return query, args
+}
 
-	stmt, err := db.Prepare(query)
+// GetTags lists the most used tags of the webring, alphabetically
+func GetTags(db *sql.DB, limit int) []types.Tag {
+	rows, err := db.Query(`
+	SELECT tag, count FROM (
+		SELECT t.tag AS tag, COUNT(*) AS count FROM tags t INNER JOIN pages p ON t.url = p.url
+		WHERE p.duplicate_of IS NULL
+		GROUP BY t.tag ORDER BY count DESC LIMIT ?
+	) ORDER BY tag`, limit)
 	util.Check(err)
-	defer stmt.Close()
+	defer rows.Close()
 
-	rows, err := stmt.Query(args...)
+	var tags []types.Tag
+	var tag types.Tag
+	for rows.Next() {
+		err = rows.Scan(&tag.Name, &tag.Count)
+		util.Check(err)
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// GetVocabulary counts the pages each of the indexed words occurs on
+func GetVocabulary(db *sql.DB) map[string]int {
+	rows, err := db.Query("SELECT word, COUNT(DISTINCT url) FROM inv_index GROUP BY word")
 	util.Check(err)
 	defer rows.Close()
 
-	var pageData types.PageData
-	pages := make([]types.PageData, 0, 15)
+	vocabulary := make(map[string]int)
+	var word string
+	var count int
 	for rows.Next() {
-		if err := rows.Scan(&pageData.URL, &pageData.About, &pageData.Title); err != nil {
-			log.Fatalln(err)
+		err = rows.Scan(&word, &count)
+		util.Check(err)
+		vocabulary[word] = count
+	}
+	return vocabulary
+}
+
+// GetWordForms maps the indexed words—the stems of the words on the pages—to the form they were most often found in,
+// e.g. geschicht to geschichten. words found in their indexed form aren't listed.
+func GetWordForms(db *sql.DB) map[string]string {
+	rows, err := db.Query("SELECT word, form FROM word_forms")
+	util.Check(err)
+	defer rows.Close()
+
+	forms := make(map[string]string)
+	var word, form string
+	for rows.Next() {
+		err = rows.Scan(&word, &form)
+		util.Check(err)
+		forms[word] = form
+	}
+	return forms
+}
+
+// GetTitles lists the distinct titles of the pages, leaving out near-duplicates
+func GetTitles(db *sql.DB) []string {
+	rows, err := db.Query("SELECT DISTINCT title FROM pages WHERE duplicate_of IS NULL AND title IS NOT NULL AND title != ''")
+	util.Check(err)
+	defer rows.Close()
+
+	var titles []string
+	var title string
+	for rows.Next() {
+		err = rows.Scan(&title)
+		util.Check(err)
+		titles = append(titles, title)
+	}
+	return titles
+}
+
+// GetPeople lists the names and handles of the people behind each of the webring's domains
+func GetPeople(db *sql.DB) []types.Person {
+	rows, err := db.Query("SELECT domain, name, kind FROM people ORDER BY domain, kind DESC, name")
+	util.Check(err)
+	defer rows.Close()
+
+	var people []types.Person
+	var domain, name, kind string
+	for rows.Next() {
+		err = rows.Scan(&domain, &name, &kind)
+		util.Check(err)
+		if len(people) == 0 || people[len(people)-1].Domain != domain {
+			people = append(people, types.Person{Domain: domain})
+		}
+		person := &people[len(people)-1]
+		if kind == "handle" {
+			person.Handles = append(person.Handles, name)
+		} else {
+			person.Names = append(person.Names, name)
 		}
-		pages = append(pages, pageData)
 	}
-	return pages
+	return people
 }
 
 func InsertManyDomains(db *sql.DB, pages []types.PageData) {
@@ -433,14 +850,14 @@ func InsertManyPages(db *sql.DB, pages []types.PageData) {
 	args := make([]interface{}, 0, len(pages))
 
 	for _, b := range pages {
-		// url, title, lang, about, domain
-		values = append(values, "(?, ?, ?, ?, ?)")
+		// url, title, lang, declared_lang, detected_lang, lang_confidence, about, published, author, domain
+		values = append(values, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 		u, err := url.Parse(b.URL)
 		util.Check(err)
-		args = append(args, b.URL, b.Title, b.Lang, b.About, u.Hostname())
+		args = append(args, b.URL, b.Title, b.Lang, b.DeclaredLang, b.DetectedLang, b.LangConfidence, b.About, b.Published, b.Author, u.Hostname())
 	}
 
-	stmt := fmt.Sprintf(`INSERT OR IGNORE INTO pages(url, title, lang, about, domain) VALUES %s`, strings.Join(values, ","))
+	stmt := fmt.Sprintf(`INSERT OR IGNORE INTO pages(url, title, lang, declared_lang, detected_lang, lang_confidence, about, published, author, domain) VALUES %s`, strings.Join(values, ","))
 	_, err := db.Exec(stmt, args...)
 	util.Check(err)
 }
@@ -455,16 +872,20 @@ func InsertManyWords(db *sql.DB, batch []types.SearchFragment) {
 
 	for _, b := range batch {
 		pageurl := strings.TrimSuffix(b.URL, "/")
-		values = append(values, "(?, ?, ?)")
-		args = append(args, b.Word, pageurl, b.Score)
+		field := b.Field
+		if field == "" {
+			field = "body"
+		}
+		values = append(values, "(?, ?, ?, ?)")
+		args = append(args, b.Word, pageurl, b.Score, field)
 	}
 
-	stmt := fmt.Sprintf(`INSERT OR IGNORE INTO inv_index(word, url, score) VALUES %s`, strings.Join(values, ","))
+	stmt := fmt.Sprintf(`INSERT OR IGNORE INTO inv_index(word, url, score, field) VALUES %s`, strings.Join(values, ","))
 	_, err := db.Exec(stmt, args...)
 	util.Check(err)
 }
 
-func InsertManyExternalLinks(db *sql.DB, externalLinks []string) {
+func InsertManyExternalLinks(db *sql.DB, externalLinks []types.Link) {
 	if len(externalLinks) == 0 {
 		return
 	}
@@ -473,11 +894,67 @@ func InsertManyExternalLinks(db *sql.DB, externalLinks []string) {
 	args := make([]interface{}, 0, len(externalLinks))
 
 	for _, externalLink := range externalLinks {
-		values = append(values, "(?)")
-		args = append(args, externalLink)
+		// url, source, domain, anchor
+		values = append(values, "(?, ?, ?, ?)")
+		u, err := url.Parse(externalLink.Source)
+		util.Check(err)
+		args = append(args, externalLink.URL, externalLink.Source, u.Hostname(), externalLink.Anchor)
+	}
+
+	stmt := fmt.Sprintf(`INSERT OR IGNORE INTO external_link_sources(url, source, domain, anchor) VALUES %s`, strings.Join(values, ","))
+	_, err := db.Exec(stmt, args...)
+	util.Check(err)
+}
+
+func InsertManyAnchors(db *sql.DB, anchors []types.Link) {
+	if len(anchors) == 0 {
+		return
 	}
 
-	stmt := fmt.Sprintf(`INSERT OR IGNORE INTO external_links(url) VALUES %s`, strings.Join(values, ","))
+	values := make([]string, 0, len(anchors))
+	args := make([]interface{}, 0, len(anchors))
+
+	for _, anchor := range anchors {
+		// url, source, domain, anchor
+		values = append(values, "(?, ?, ?, ?)")
+		u, err := url.Parse(anchor.Source)
+		util.Check(err)
+		args = append(args, anchor.URL, anchor.Source, u.Hostname(), anchor.Anchor)
+	}
+
+	stmt := fmt.Sprintf(`INSERT OR IGNORE INTO anchors(url, source, domain, anchor) VALUES %s`, strings.Join(values, ","))
+	_, err := db.Exec(stmt, args...)
+	util.Check(err)
+}
+
+// BuildExternalLinkSearch indexes each outgoing link once, together with its title and the anchor texts it was linked
+// with, for FulltextSearchWords. it is run once all of the links and external pages have been ingested.
+func BuildExternalLinkSearch(db *sql.DB) {
+	_, err := db.Exec(`
+	INSERT INTO external_links(url, title, anchors)
+	SELECT s.url, COALESCE(ep.title, ''), COALESCE(GROUP_CONCAT(DISTINCT s.anchor), '')
+	FROM external_link_sources s LEFT JOIN external_pages ep ON ep.url = s.url
+	GROUP BY s.url
+	`)
+	util.Check(err)
+}
+
+func InsertManyExternalPages(db *sql.DB, pages []types.PageData) {
+	if len(pages) == 0 {
+		return
+	}
+	values := make([]string, 0, len(pages))
+	args := make([]interface{}, 0, len(pages))
+
+	for _, b := range pages {
+		// url, domain, title, about
+		values = append(values, "(?, ?, ?, ?)")
+		u, err := url.Parse(b.URL)
+		util.Check(err)
+		args = append(args, b.URL, u.Hostname(), b.Title, b.About)
+	}
+
+	stmt := fmt.Sprintf(`INSERT OR IGNORE INTO external_pages(url, domain, title, about) VALUES %s`, strings.Join(values, ","))
 	_, err := db.Exec(stmt, args...)
 	util.Check(err)
 }
@@ -491,11 +968,148 @@ func InsertManyBigParagraphs(db *sql.DB, paragraphPairs []types.WholeParagraph)
 	args := make([]interface{}, 0, len(paragraphPairs))
 
 	for _, paragraphPair := range paragraphPairs {
+		values = append(values, "(?, ?, ?, ?)")
+		// sqlite integers are signed
+		args = append(args, paragraphPair.Text, paragraphPair.URL, int64(paragraphPair.Fingerprint), util.SegmentCJKText(paragraphPair.Text))
+	}
+
+	stmt := fmt.Sprintf(`INSERT OR IGNORE INTO big_search(text, url, fingerprint, segmented) VALUES %s`, strings.Join(values, ","))
+	_, err := db.Exec(stmt, args...)
+	util.Check(err)
+}
+
+func InsertManyHeadings(db *sql.DB, headings []types.WholeParagraph) {
+	if len(headings) == 0 {
+		return
+	}
+
+	values := make([]string, 0, len(headings))
+	args := make([]interface{}, 0, len(headings))
+
+	for _, heading := range headings {
+		values = append(values, "(?, ?, ?)")
+		args = append(args, heading.Text, heading.URL, util.SegmentCJKText(heading.Text))
+	}
+
+	stmt := fmt.Sprintf(`INSERT INTO headings(text, url, segmented) VALUES %s`, strings.Join(values, ","))
+	_, err := db.Exec(stmt, args...)
+	util.Check(err)
+}
+
+// UpdateFingerprints stores the fingerprint of each page, and marks the pages of each cluster of near-duplicates as
+// duplicates of the cluster's representative, which will be presented in their stead
+func UpdateFingerprints(db *sql.DB, fingerprints map[string]uint64, clusters map[string][]string) {
+	tx, err := db.Begin()
+	util.Check(err)
+
+	fingerprintStmt, err := tx.Prepare(`UPDATE pages SET fingerprint = ? WHERE url = ?`)
+	util.Check(err)
+	defer fingerprintStmt.Close()
+	for pageurl, fingerprint := range fingerprints {
+		_, err = fingerprintStmt.Exec(int64(fingerprint), pageurl)
+		util.Check(err)
+	}
+
+	similarStmt, err := tx.Prepare(`UPDATE pages SET similar = ? WHERE url = ?`)
+	util.Check(err)
+	defer similarStmt.Close()
+	duplicateStmt, err := tx.Prepare(`UPDATE pages SET duplicate_of = ? WHERE url = ?`)
+	util.Check(err)
+	defer duplicateStmt.Close()
+	for representative, duplicates := range clusters {
+		_, err = similarStmt.Exec(len(duplicates), representative)
+		util.Check(err)
+		for _, duplicate := range duplicates {
+			_, err = duplicateStmt.Exec(representative, duplicate)
+			util.Check(err)
+		}
+	}
+	util.Check(tx.Commit())
+}
+
+func InsertManyImages(db *sql.DB, images []types.Image) {
+	if len(images) == 0 {
+		return
+	}
+
+	values := make([]string, 0, len(images))
+	args := make([]interface{}, 0, len(images))
+
+	for _, image := range images {
+		values = append(values, "(?, ?, ?)")
+		args = append(args, image.Alt, image.Src, image.URL)
+	}
+
+	stmt := fmt.Sprintf(`INSERT OR IGNORE INTO images(alt, src, url) VALUES %s`, strings.Join(values, ","))
+	_, err := db.Exec(stmt, args...)
+	util.Check(err)
+}
+
+func InsertManyPeople(db *sql.DB, people []types.Person) {
+	if len(people) == 0 {
+		return
+	}
+
+	values := make([]string, 0, len(people))
+	args := make([]interface{}, 0, len(people))
+
+	for _, person := range people {
+		for _, name := range person.Names {
+			values = append(values, "(?, ?, ?)")
+			args = append(args, person.Domain, name, "name")
+		}
+		for _, handle := range person.Handles {
+			values = append(values, "(?, ?, ?)")
+			args = append(args, person.Domain, handle, "handle")
+		}
+	}
+
+	// a forum can have thousands of authors, so the names are inserted 3000 at a time to stay below sqlite's limit on
+	// the number of variables in a statement
+	for i := 0; i < len(values); i += 3000 {
+		end_i := i + 3000
+		if end_i > len(values) {
+			end_i = len(values)
+		}
+		stmt := fmt.Sprintf(`INSERT OR IGNORE INTO people(domain, name, kind) VALUES %s`, strings.Join(values[i:end_i], ","))
+		_, err := db.Exec(stmt, args[3*i:3*end_i]...)
+		util.Check(err)
+	}
+}
+
+func InsertWordForms(db *sql.DB, forms map[string]string) {
+	values := make([]string, 0, len(forms))
+	args := make([]interface{}, 0, 2*len(forms))
+	for word, form := range forms {
 		values = append(values, "(?, ?)")
-		args = append(args, paragraphPair.Text, paragraphPair.URL)
+		args = append(args, word, form)
+	}
+	for i := 0; i < len(values); i += 3000 {
+		end_i := i + 3000
+		if end_i > len(values) {
+			end_i = len(values)
+		}
+		stmt := fmt.Sprintf(`INSERT OR IGNORE INTO word_forms(word, form) VALUES %s`, strings.Join(values[i:end_i], ","))
+		_, err := db.Exec(stmt, args[2*i:2*end_i]...)
+		util.Check(err)
+	}
+}
+
+func InsertManyTags(db *sql.DB, pages []types.PageData) {
+	values := make([]string, 0, len(pages))
+	args := make([]interface{}, 0, len(pages))
+
+	for _, b := range pages {
+		for _, tag := range b.Tags {
+			values = append(values, "(?, ?)")
+			args = append(args, b.URL, tag)
+		}
+	}
+	if len(values) == 0 {
+		return
 	}
 
-	stmt := fmt.Sprintf(`INSERT OR IGNORE INTO big_search(text, url) VALUES %s`, strings.Join(values, ","))
+	stmt := fmt.Sprintf(`INSERT OR IGNORE INTO tags(url, tag) VALUES %s`, strings.Join(values, ","))
 	_, err := db.Exec(stmt, args...)
 	util.Check(err)
 }
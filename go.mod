@@ -4,9 +4,12 @@ go 1.14
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.1
+	github.com/abadojack/whatlanggo v1.0.1
+	github.com/blevesearch/snowballstem v0.9.0
 	github.com/gocolly/colly/v2 v2.1.0
 	github.com/jinzhu/inflection v1.0.0
 	github.com/komkom/toml v0.0.0-20210129103441-ff0648d25a4b
 	github.com/mattn/go-sqlite3 v1.14.6
-	github.com/microcosm-cc/bluemonday v1.0.27 // indirect
+	github.com/microcosm-cc/bluemonday v1.0.27
+	golang.org/x/text v0.16.0
 )
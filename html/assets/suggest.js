@@ -0,0 +1,32 @@
+// offers completions in the search box as you type, using the /suggest endpoint. the search box works just the same
+// without it.
+(function () {
+    const input = document.querySelector("input[list=suggestions]")
+    const list = document.getElementById("suggestions")
+    if (!input || !list || !window.fetch) { return }
+    // the browser's own history of searches would otherwise cover the completions; without javascript, it is kept
+    input.setAttribute("autocomplete", "off")
+
+    let timeout
+    let current
+    input.addEventListener("input", function () {
+        clearTimeout(timeout)
+        timeout = setTimeout(function () {
+            const query = input.value
+            if (query.trim().length === 0 || query === current) { return }
+            current = query
+            fetch("/suggest?q=" + encodeURIComponent(query))
+                .then(function (res) { return res.json() })
+                .then(function (data) {
+                    // the query has changed since, and its own completions are on their way
+                    if (data[0] !== input.value) { return }
+                    list.replaceChildren(...data[1].map(function (completion) {
+                        const option = document.createElement("option")
+                        option.value = completion
+                        return option
+                    }))
+                })
+                .catch(function () {})
+        }, 150)
+    })
+})()
@@ -0,0 +1,78 @@
+package ingest
+
+import (
+	"sort"
+
+	"gomod.cblgh.org/lieu/util"
+)
+
+// pages with fewer words than this have too little content to be compared; e.g. two near empty pages would
+// otherwise always be considered duplicates of each other
+const minFingerprintFeatures = 30
+
+// the same goes for paragraphs, which are shorter: paragraphs made up of stopwords would all get the same fingerprint
+const minParagraphFeatures = 8
+
+// clusterNearDuplicates groups pages whose fingerprints are near-duplicates of each other. the returned map is keyed
+// by the representative of each cluster—its shortest url—and lists the other pages of the cluster.
+//
+// comparing every page with every other page doesn't scale, so fingerprints are split into four 16 bit blocks: since
+// near-duplicates differ in at most util.NearDuplicateDistance (< 4) bits, they must have at least one block in common,
+// and only pages sharing a block are compared.
+func clusterNearDuplicates(fingerprints map[string]uint64) map[string][]string {
+	urls := make([]string, 0, len(fingerprints))
+	for pageurl := range fingerprints {
+		urls = append(urls, pageurl)
+	}
+	sort.Strings(urls)
+
+	parent := make(map[string]string, len(urls))
+	var root func(string) string
+	root = func(pageurl string) string {
+		if parent[pageurl] == pageurl {
+			return pageurl
+		}
+		parent[pageurl] = root(parent[pageurl])
+		return parent[pageurl]
+	}
+	for _, pageurl := range urls {
+		parent[pageurl] = pageurl
+	}
+
+	for block := 0; block < 4; block++ {
+		buckets := make(map[uint64][]string)
+		for _, pageurl := range urls {
+			key := (fingerprints[pageurl] >> uint(block*16)) & 0xffff
+			buckets[key] = append(buckets[key], pageurl)
+		}
+		for _, bucket := range buckets {
+			for i := 0; i < len(bucket); i++ {
+				for j := i + 1; j < len(bucket); j++ {
+					if util.IsNearDuplicate(fingerprints[bucket[i]], fingerprints[bucket[j]]) {
+						parent[root(bucket[i])] = root(bucket[j])
+					}
+				}
+			}
+		}
+	}
+
+	members := make(map[string][]string)
+	for _, pageurl := range urls {
+		r := root(pageurl)
+		members[r] = append(members[r], pageurl)
+	}
+	clusters := make(map[string][]string)
+	for _, cluster := range members {
+		if len(cluster) < 2 {
+			continue
+		}
+		sort.Slice(cluster, func(i, j int) bool {
+			if len(cluster[i]) != len(cluster[j]) {
+				return len(cluster[i]) < len(cluster[j])
+			}
+			return cluster[i] < cluster[j]
+		})
+		clusters[cluster[0]] = cluster[1:]
+	}
+	return clusters
+}
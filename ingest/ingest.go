@@ -4,9 +4,11 @@ import (
 	"bufio"
 	"database/sql"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -14,8 +16,6 @@ import (
 	"gomod.cblgh.org/lieu/database"
 	"gomod.cblgh.org/lieu/types"
 	"gomod.cblgh.org/lieu/util"
-
-	"github.com/jinzhu/inflection"
 )
 
 func partitionSentence(s string) []string {
@@ -30,17 +30,58 @@ func partitionSentence(s string) []string {
 	s = symbols.ReplaceAllString(s, " ")
 	s = strings.ReplaceAll(s, "|", " ")
 	s = strings.ReplaceAll(s, "/", " ")
-	return strings.Fields(s)
+	return util.SegmentCJK(strings.Fields(s))
+}
+
+// analyzer turns the words of a page into the terms they are indexed as
+type analyzer struct {
+	// maps each language to its words which are stopped from entering the search index
+	stopwords   map[string]map[string]bool
+	foldAccents bool
+}
+
+// newAnalyzer reads the english stopwords from the wordlist, and those of the stemmed languages from the stopwords
+// directory, e.g. de.txt for german
+func newAnalyzer(config types.Config) analyzer {
+	a := analyzer{stopwords: make(map[string]map[string]bool), foldAccents: config.General.FoldAccents}
+	a.stopwords["en"] = toSet(util.ReadList(config.Data.Wordlist, "|"))
+	for _, lang := range util.StemmedLanguages {
+		a.stopwords[lang] = toSet(util.ReadList(filepath.Join(config.Data.Stopwords, lang+".txt"), "\n"))
+	}
+	return a
+}
+
+// stopwordsOf returns the stopwords of a language; languages without stopwords of their own use the english ones
+func (a analyzer) stopwordsOf(lang string) map[string]bool {
+	if words, exists := a.stopwords[util.BaseLanguage(lang)]; exists {
+		return words
+	}
+	return a.stopwords["en"]
 }
 
-func filterCommonWords(words, wordlist []string) []string {
+func toSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[util.NormalizeText(strings.TrimSpace(word))] = true
+	}
+	return set
+}
+
+// filterCommonWords drops the stopwords of the language, and stems the remaining words. accents are folded only after
+// stemming, as the stemmers rely on them.
+func filterCommonWords(words []string, lang string, a analyzer) []string {
+	stopped := a.stopwordsOf(lang)
 	filtered := make([]string, 0, len(words))
 	for _, word := range words {
 		// ingested word was too common, skip it
-		if len(word) == 1 || find(wordlist, word) {
+		if len(word) == 1 || stopped[word] {
 			continue
 		}
-		filtered = append(filtered, inflection.Singular(word))
+		word = util.Stem(word, lang)
+		if a.foldAccents {
+			word = util.FoldAccents(word)
+		}
+		filtered = append(filtered, word)
 	}
 	return filtered
 }
@@ -61,16 +102,19 @@ func performAboutHeuristic(heuristicPath, phrase string) bool {
 }
 
 func Ingest(config types.Config) {
-	if _, err := os.Stat(config.Data.Database); err == nil || os.IsExist(err) {
-		err = os.Remove(config.Data.Database)
+	// the database is built next to the one in use, which it replaces once it is complete: a running lieu host keeps
+	// searching the previous database until then
+	building := config.Data.Database + ".ingesting"
+	if _, err := os.Stat(building); err == nil || os.IsExist(err) {
+		err = os.Remove(building)
 		util.Check(err)
 	}
 
-	db := database.InitDB(config.Data.Database)
+	db := database.InitBulkDB(building)
 	date := time.Now().Format("2006-01-02")
 	database.UpdateCrawlDate(db, date)
 
-	wordlist := util.ReadList(config.Data.Wordlist, "|")
+	common := newAnalyzer(config)
 
 	buf, err := os.Open(config.Data.Source)
 	util.Check(err)
@@ -80,12 +124,35 @@ func Ingest(config types.Config) {
 		util.Check(err)
 	}()
 
+	pageURLs := resolvePageURLs(config.Data.Source)
+	// the variant of a page (e.g. its http:// url) whose data was ingested; the other variants' data are duplicates
+	variants := make(map[string]string)
+
+	// used to find pages which are near-duplicates of each other, once everything has been ingested
+	simhashes := make(map[string]*util.SimHash)
+	// used to detect the language of pages, before each batch is ingested
+	samples := make(languageSamples)
+	// used to suggest corrected queries using the words as they were written, rather than their stems
+	forms := make(wordForms)
+
 	pages := make(map[string]types.PageData)
 	var count int
 	batchsize := 100
 	batch := make([]types.SearchFragment, 0, batchsize)
-	var externalLinks []string
+	// the words of the batch's pages, which are only stemmed once the language of each page is known
+	var unstemmed []types.SearchFragment
+	var externalLinks []types.Link
+	var anchors []types.Link
+	var images []types.Image
+	people := make(peopleByDomain)
+	// the anchor texts of the links between the webring's pages, whose words are indexed once the language of every
+	// linking page is known
+	var anchorTexts []types.Link
+	langs := make(map[string]string)
 	paragraphPairs := make([]types.WholeParagraph, 0, 0)
+	// the headings of the pages, which link results' snippets are taken from alongside the paragraphs
+	headings := make([]types.WholeParagraph, 0, 0)
+	externalPages := make(map[string]types.PageData)
 
 	scanner := bufio.NewScanner(buf)
 	for scanner.Scan() {
@@ -97,11 +164,43 @@ func Ingest(config types.Config) {
 			continue
 		}
 
-		pageurl := strings.TrimSuffix(strings.TrimSpace(line[lastSpace:]), "/")
+		pageurl := util.NormalizeURL(line[lastSpace:])
 		if !strings.HasPrefix(pageurl, "http") {
 			continue
 		}
 
+		token := line[0:firstSpace]
+		rawdata := strings.TrimSpace(line[firstSpace:lastSpace])
+		payload := util.NormalizeText(rawdata)
+
+		// data on the pages outside of the webring, gathered by visiting the outgoing links once
+		if strings.HasPrefix(token, "external-") {
+			// stored under the url the page was linked with, as the outgoing links are
+			pageurl = strings.TrimSpace(line[lastSpace:])
+			externalPage := externalPages[pageurl]
+			externalPage.URL = pageurl
+			switch token {
+			case "external-title":
+				externalPage.Title = rawdata
+			case "external-desc":
+				if len(externalPage.About) == 0 {
+					externalPage.About = rawdata
+				}
+			}
+			externalPages[pageurl] = externalPage
+			continue
+		}
+
+		if resolved, exists := pageURLs[pageurl]; exists {
+			// compare the urls as crawled: e.g. https://example.com and https://example.com/ may both have been crawled
+			rawurl := strings.TrimSpace(line[lastSpace:])
+			if variant, seen := variants[resolved]; seen && variant != rawurl {
+				continue
+			}
+			variants[resolved] = rawurl
+			pageurl = resolved
+		}
+
 		var page types.PageData
 		if data, exists := pages[pageurl]; exists {
 			page = data
@@ -109,10 +208,6 @@ func Ingest(config types.Config) {
 			page.URL = pageurl
 		}
 
-		token := line[0:firstSpace]
-		rawdata := strings.TrimSpace(line[firstSpace:lastSpace])
-		payload := strings.ToLower(rawdata)
-
 		var processed []string
 		score := 1
 		switch token {
@@ -122,7 +217,44 @@ func Ingest(config types.Config) {
 				page.AboutSource = token
 			}
 			score = 5
+			if page.TitleSource != "meta-name" {
+				page.Title = rawdata
+				page.TitleSource = token
+			}
+			processed = partitionSentence(payload)
+		case "meta-name":
+			if len(page.About) == 0 || page.AboutSource == "title" {
+				page.About = rawdata
+				page.AboutSource = token
+			}
+			score = 5
 			page.Title = rawdata
+			page.TitleSource = token
+			processed = partitionSentence(payload)
+		case "meta-summary":
+			if !page.NoSnippet {
+				page.About = rawdata
+				page.AboutSource = token
+			}
+			processed = partitionSentence(payload)
+		case "meta-published":
+			page.Published = rawdata
+		case "meta-author":
+			// every author is kept, so that author: finds the pages of co-authors too. they aren't added to the people
+			// of the domain, which only lists those the site presents as itself—on a forum, every poster is an author
+			if len(page.Author) == 0 {
+				page.Author = rawdata
+			} else if !strings.Contains(page.Author, rawdata) {
+				page.Author += ", " + rawdata
+			}
+			processed = partitionSentence(payload)
+		case "person-name":
+			people.add(getDomain(pageurl), rawdata, "name")
+		case "person-handle":
+			people.add(getDomain(pageurl), rawdata, "handle")
+		case "meta-tag":
+			page.Tags = addTag(page.Tags, rawdata)
+			score = 3
 			processed = partitionSentence(payload)
 		case "h1":
 			if len(page.About) == 0 {
@@ -134,19 +266,42 @@ func Ingest(config types.Config) {
 			fallthrough
 		case "h3":
 			score = 15
+			if !page.NoSnippet {
+				headings = append(headings, types.WholeParagraph{Text: util.CleanTextStrict(rawdata), URL: pageurl})
+			}
+			processed = partitionSentence(payload)
+		case "img":
+			// the image's url, followed by its alt text
+			fields := strings.SplitN(rawdata, " ", 2)
+			if len(fields) < 2 {
+				break
+			}
+			alt := strings.TrimSpace(fields[1])
+			if !page.NoSnippet {
+				images = append(images, types.Image{Src: fields[0], URL: pageurl, Alt: alt})
+			}
+			score = 3
+			processed = partitionSentence(util.NormalizeText(alt))
+		case "figcaption":
+			score = 3
+			processed = partitionSentence(payload)
+		case "title-attr":
+			score = 2
 			processed = partitionSentence(payload)
 		case "desc":
-			if len(page.About) < 30 && len(rawdata) < 100 && len(rawdata) > len(page.About) {
+			if !page.NoSnippet && page.AboutSource != "meta-summary" && len(page.About) < 30 && len(rawdata) < 100 && len(rawdata) > len(page.About) {
 				page.About = rawdata
 				page.AboutSource = token
 			}
 			processed = partitionSentence(payload)
 		case "og-desc":
-			page.About = rawdata
-			page.AboutSource = token
+			if !page.NoSnippet && page.AboutSource != "meta-summary" {
+				page.About = rawdata
+				page.AboutSource = token
+			}
 			processed = partitionSentence(payload)
 		case "para":
-			if page.AboutSource != "og-desc" || len(rawdata)*10 > len(page.About)*7 {
+			if !page.NoSnippet && page.AboutSource != "meta-summary" && (page.AboutSource != "og-desc" || len(rawdata)*10 > len(page.About)*7) {
 				if performAboutHeuristic(config.Data.Heuristics, payload) {
 					page.About = rawdata
 					page.AboutSource = token
@@ -155,22 +310,64 @@ func Ingest(config types.Config) {
 			processed = partitionSentence(payload)
 		case "lang":
 			page.Lang = rawdata
+			page.DeclaredLang = rawdata
 		case "keywords":
 			processed = strings.Split(strings.ReplaceAll(payload, ", ", ","), ",")
+			for _, keyword := range processed {
+				page.Tags = addTag(page.Tags, keyword)
+			}
 		case "non-webring-link":
-			externalLinks = append(externalLinks, rawdata)
+			// the outgoing link, optionally followed by its anchor text
+			fields := strings.SplitN(rawdata, " ", 2)
+			link := types.Link{URL: fields[0], Source: pageurl}
+			if len(fields) > 1 {
+				link.Anchor = strings.TrimSpace(fields[1])
+			}
+			externalLinks = append(externalLinks, link)
+		case "anchor":
+			// the linked webring page, followed by the link's anchor text
+			fields := strings.SplitN(rawdata, " ", 2)
+			target := util.NormalizeURL(fields[0])
+			resolved, exists := pageURLs[target]
+			if !exists || resolved == pageurl || len(fields) < 2 {
+				break
+			}
+			anchor := types.Link{URL: resolved, Source: pageurl, Anchor: strings.TrimSpace(fields[1])}
+			anchors = append(anchors, anchor)
+			anchorTexts = append(anchorTexts, anchor)
+		case "robots":
+			// the page asked for none of its text to be shown as a preview; only its title may describe it
+			if rawdata == "nosnippet" {
+				page.NoSnippet = true
+				if page.AboutSource != "title" && page.AboutSource != "h1" && page.AboutSource != "meta-name" {
+					page.About = ""
+					page.AboutSource = ""
+				}
+			}
 		case "big-para":
-			paragraphPairs = append(paragraphPairs, types.WholeParagraph{Text: rawdata, URL: pageurl})
+			if page.NoSnippet {
+				break
+			}
+			paragraphWords := filterCommonWords(partitionSentence(payload), page.Lang, common)
+			// a paragraph of only a few words, besides its stopwords, is left without a fingerprint (0)
+			var fingerprint uint64
+			if len(paragraphWords) >= minParagraphFeatures {
+				fingerprint = util.Fingerprint(paragraphWords)
+			}
+			paragraphPairs = append(paragraphPairs, types.WholeParagraph{Text: rawdata, URL: pageurl, Fingerprint: fingerprint})
+			addFeatures(simhashes, pageurl, paragraphWords)
 		default:
 			continue
 		}
 
-		pages[pageurl] = page
-		processed = filterCommonWords(processed, wordlist)
-		count += len(processed)
+		switch token {
+		case "title", "meta-name", "meta-summary", "h1", "h2", "h3", "para", "big-para", "desc", "og-desc", "figcaption":
+			samples.add(pageurl, rawdata)
+		}
 
+		pages[pageurl] = page
 		for _, word := range processed {
-			batch = append(batch, types.SearchFragment{Word: word, URL: pageurl, Score: score})
+			unstemmed = append(unstemmed, types.SearchFragment{Word: word, URL: pageurl, Score: score})
 		}
 		if token == "title" {
 			// only extract path segments once per url.
@@ -182,22 +379,219 @@ func Ingest(config types.Config) {
 		}
 
 		if len(pages) > batchsize {
-			ingestBatch(db, batch, pages, externalLinks, paragraphPairs)
-			externalLinks = make([]string, 0, 0)
+			detectLanguages(pages, samples)
+			recordLanguages(langs, pages)
+			stemmed := stemBatch(unstemmed, pages, common, simhashes, forms)
+			count += len(stemmed)
+			batch = append(batch, stemmed...)
+			ingestBatch(db, batch, pages, externalLinks, anchors, images, paragraphPairs, headings)
+			externalLinks = make([]types.Link, 0, 0)
+			anchors = make([]types.Link, 0, 0)
+			images = make([]types.Image, 0, 0)
 			paragraphPairs = make([]types.WholeParagraph, 0, 0)
+			headings = make([]types.WholeParagraph, 0, 0)
 			batch = make([]types.SearchFragment, 0, batchsize)
+			unstemmed = make([]types.SearchFragment, 0, 0)
 			// TODO: make sure we don't partially insert any page data
 			pages = make(map[string]types.PageData)
 		}
 	}
-	ingestBatch(db, batch, pages, externalLinks, paragraphPairs)
+	detectLanguages(pages, samples)
+	recordLanguages(langs, pages)
+	stemmed := stemBatch(unstemmed, pages, common, simhashes, forms)
+	count += len(stemmed)
+	batch = append(batch, stemmed...)
+	ingestBatch(db, batch, pages, externalLinks, anchors, images, paragraphPairs, headings)
+	ingestExternalPages(db, externalPages)
+	count += ingestAnchorWords(db, anchorTexts, langs, common)
+	database.InsertManyPeople(db, people.list())
+	database.InsertWordForms(db, forms.mostFrequent())
+	database.BuildExternalLinkSearch(db)
 	fmt.Printf("ingested %d words\n", count)
 
+	fingerprints := make(map[string]uint64)
+	for pageurl, simhash := range simhashes {
+		if simhash.Features >= minFingerprintFeatures {
+			fingerprints[pageurl] = simhash.Sum()
+		}
+	}
+	clusters := clusterNearDuplicates(fingerprints)
+	database.UpdateFingerprints(db, fingerprints, clusters)
+	fmt.Printf("found %d clusters of near-duplicate pages\n", len(clusters))
+
+	log.Println("starting to build the indexes")
+	database.CreateIndexes(db)
+	database.Analyze(db)
+	log.Println("finished building the indexes")
+
 	err = scanner.Err()
 	util.Check(err)
+	err = db.Close()
+	util.Check(err)
+	err = os.Rename(building, config.Data.Database)
+	util.Check(err)
 }
 
-func ingestBatch(db *sql.DB, batch []types.SearchFragment, pageMap map[string]types.PageData, links []string, paragraphPairs []types.WholeParagraph) {
+// wordForms counts the forms each indexed word was found in, e.g. geschichten and geschichte for geschicht
+type wordForms map[string]map[string]int
+
+func (f wordForms) add(word, form string) {
+	if _, exists := f[word]; !exists {
+		f[word] = make(map[string]int)
+	}
+	f[word][form]++
+}
+
+// mostFrequent returns the form each word was most often found in, leaving out the words most often found as they are
+// indexed
+func (f wordForms) mostFrequent() map[string]string {
+	frequent := make(map[string]string)
+	for word, counts := range f {
+		best := word
+		for form, count := range counts {
+			if count > counts[best] || (count == counts[best] && form < best) {
+				best = form
+			}
+		}
+		if best != word {
+			frequent[word] = best
+		}
+	}
+	return frequent
+}
+
+// stemBatch drops the stopwords of the batch's words and stems the rest, according to the language of their page
+func stemBatch(unstemmed []types.SearchFragment, pages map[string]types.PageData, common analyzer, simhashes map[string]*util.SimHash, forms wordForms) []types.SearchFragment {
+	stemmed := make([]types.SearchFragment, 0, len(unstemmed))
+	for _, fragment := range unstemmed {
+		words := filterCommonWords([]string{fragment.Word}, pages[fragment.URL].Lang, common)
+		if len(words) == 0 {
+			continue
+		}
+		addFeatures(simhashes, fragment.URL, words)
+		forms.add(words[0], fragment.Word)
+		fragment.Word = words[0]
+		stemmed = append(stemmed, fragment)
+	}
+	return stemmed
+}
+
+func addFeatures(simhashes map[string]*util.SimHash, pageurl string, words []string) {
+	simhash, exists := simhashes[pageurl]
+	if !exists {
+		simhash = &util.SimHash{}
+		simhashes[pageurl] = simhash
+	}
+	for _, word := range words {
+		simhash.Add(word, 1)
+	}
+}
+
+// resolvePageURLs maps the url of every crawled page to the url its data will be stored under. variants of the same
+// page are collapsed into one: urls are replaced by the canonical url the page declared, and if a page was crawled
+// over both http and https the https url is preferred.
+//
+// site templates often declare the same canonical url—e.g. the homepage—for every page, so a canonical url is only
+// honored if it is a variant of the page's url, or if the page it points to was crawled with the same content.
+func resolvePageURLs(sourcePath string) map[string]string {
+	buf, err := os.Open(sourcePath)
+	util.Check(err)
+	defer buf.Close()
+
+	canonicals := make(map[string]string)
+	// the content of each page, hashed so that the order of its lines doesn't matter
+	contents := make(map[string]uint64)
+	// the crawled pages, in the order they were first seen
+	var pageurls []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		line := scanner.Text()
+		firstSpace := strings.Index(line, " ")
+		lastSpace := strings.LastIndex(line, " ")
+		if firstSpace == -1 || firstSpace == lastSpace {
+			continue
+		}
+		pageurl := util.NormalizeURL(line[lastSpace:])
+		if !strings.HasPrefix(pageurl, "http") || strings.HasPrefix(line, "external-") {
+			continue
+		}
+		if !seen[pageurl] {
+			seen[pageurl] = true
+			pageurls = append(pageurls, pageurl)
+		}
+		if line[0:firstSpace] == "canonical" {
+			canonical := util.NormalizeURL(line[firstSpace:lastSpace])
+			if sameSite(pageurl, canonical) {
+				canonicals[pageurl] = canonical
+			}
+			continue
+		}
+		hash := fnv.New64a()
+		hash.Write([]byte(line[:lastSpace]))
+		contents[pageurl] += hash.Sum64()
+	}
+	util.Check(scanner.Err())
+
+	for pageurl, canonical := range canonicals {
+		content, crawled := contents[canonical]
+		if !isVariant(pageurl, canonical) && !(crawled && content == contents[pageurl]) {
+			delete(canonicals, pageurl)
+		}
+	}
+
+	resolved := make(map[string]string)
+	// the preferred url for each page, keyed by its url sans scheme
+	preferred := make(map[string]string)
+	for _, pageurl := range pageurls {
+		target := pageurl
+		if canonical, exists := canonicals[pageurl]; exists {
+			target = canonical
+		}
+		resolved[pageurl] = target
+		key := stripScheme(target)
+		if current, exists := preferred[key]; !exists || (strings.HasPrefix(target, "https://") && !strings.HasPrefix(current, "https://")) {
+			preferred[key] = target
+		}
+	}
+	for pageurl, target := range resolved {
+		resolved[pageurl] = preferred[stripScheme(target)]
+	}
+	return resolved
+}
+
+func stripScheme(pageurl string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(pageurl, "https://"), "http://")
+}
+
+// isVariant returns whether two urls are the same page, differing only in their scheme, a www. prefix or an index file
+// (which NormalizeURL has removed)
+func isVariant(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return sameSite(a, b) && ua.Path == ub.Path && ua.RawQuery == ub.RawQuery
+}
+
+// sameSite prevents a page from claiming to be the canonical version of a page on another site
+func sameSite(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.TrimPrefix(ua.Hostname(), "www.") == strings.TrimPrefix(ub.Hostname(), "www.")
+}
+
+func ingestBatch(db *sql.DB, batch []types.SearchFragment, pageMap map[string]types.PageData, links, anchors []types.Link, images []types.Image, paragraphPairs, headings []types.WholeParagraph) {
 	pages := make([]types.PageData, len(pageMap))
 	i := 0
 	for k := range pageMap {
@@ -208,6 +602,7 @@ func ingestBatch(db *sql.DB, batch []types.SearchFragment, pageMap map[string]ty
 	log.Println("starting to ingest batch (Pages:", len(pages), "Words:", len(batch), "Links:", len(links), ")")
 	database.InsertManyDomains(db, pages)
 	database.InsertManyPages(db, pages)
+	database.InsertManyTags(db, pages)
 	for i := 0; i < len(batch); i += 3000 {
 		end_i := i + 3000
 		if end_i > len(batch) {
@@ -215,11 +610,149 @@ func ingestBatch(db *sql.DB, batch []types.SearchFragment, pageMap map[string]ty
 		}
 		database.InsertManyWords(db, batch[i:end_i])
 	}
-	database.InsertManyExternalLinks(db, links)
-	database.InsertManyBigParagraphs(db, paragraphPairs)
+	for i := 0; i < len(links); i += 3000 {
+		end_i := i + 3000
+		if end_i > len(links) {
+			end_i = len(links)
+		}
+		database.InsertManyExternalLinks(db, links[i:end_i])
+	}
+	for i := 0; i < len(anchors); i += 3000 {
+		end_i := i + 3000
+		if end_i > len(anchors) {
+			end_i = len(anchors)
+		}
+		database.InsertManyAnchors(db, anchors[i:end_i])
+	}
+	for i := 0; i < len(paragraphPairs); i += 3000 {
+		end_i := i + 3000
+		if end_i > len(paragraphPairs) {
+			end_i = len(paragraphPairs)
+		}
+		database.InsertManyBigParagraphs(db, paragraphPairs[i:end_i])
+	}
+	for i := 0; i < len(headings); i += 3000 {
+		end_i := i + 3000
+		if end_i > len(headings) {
+			end_i = len(headings)
+		}
+		database.InsertManyHeadings(db, headings[i:end_i])
+	}
+	for i := 0; i < len(images); i += 3000 {
+		end_i := i + 3000
+		if end_i > len(images) {
+			end_i = len(images)
+		}
+		database.InsertManyImages(db, images[i:end_i])
+	}
 	log.Println("finished ingesting batch")
 }
 
+// the score of each word a page has been linked with; boosted above the page's own text, as how others describe a
+// page is often a better summary than the page itself
+const anchorScore = 10
+
+// recordLanguages keeps the language of each of the batch's pages, for the anchor texts they link with
+func recordLanguages(langs map[string]string, pages map[string]types.PageData) {
+	for pageurl, page := range pages {
+		langs[pageurl] = page.Lang
+	}
+}
+
+// ingestAnchorWords indexes the words of the anchor texts each page has been linked with, stemmed according to the
+// language of the linking page. the words of each linking domain are counted once, so that e.g. a site's navigation
+// doesn't drown out its pages' content.
+func ingestAnchorWords(db *sql.DB, anchorTexts []types.Link, langs map[string]string, common analyzer) int {
+	anchorWords := make(map[string]map[string]bool)
+	for _, anchor := range anchorTexts {
+		if _, exists := anchorWords[anchor.URL]; !exists {
+			anchorWords[anchor.URL] = make(map[string]bool)
+		}
+		for _, word := range filterCommonWords(partitionSentence(util.NormalizeText(anchor.Anchor)), langs[anchor.Source], common) {
+			anchorWords[anchor.URL][fmt.Sprintf("%s %s", getDomain(anchor.Source), word)] = true
+		}
+	}
+	batch := make([]types.SearchFragment, 0, len(anchorWords))
+	for pageurl, words := range anchorWords {
+		for domainWord := range words {
+			word := domainWord[strings.Index(domainWord, " ")+1:]
+			batch = append(batch, types.SearchFragment{Word: word, URL: pageurl, Score: anchorScore, Field: "anchor"})
+		}
+	}
+	log.Println("starting to ingest anchor texts (Words:", len(batch), ")")
+	for i := 0; i < len(batch); i += 3000 {
+		end_i := i + 3000
+		if end_i > len(batch) {
+			end_i = len(batch)
+		}
+		database.InsertManyWords(db, batch[i:end_i])
+	}
+	log.Println("finished ingesting anchor texts")
+	return len(batch)
+}
+
+// pages with more tags than this are most likely stuffing their keywords
+const maxTags = 20
+
+func addTag(tags []string, tag string) []string {
+	tag = util.NormalizeTag(tag)
+	if len(tag) == 0 || len(tags) >= maxTags || find(tags, tag) {
+		return tags
+	}
+	return append(tags, tag)
+}
+
+// peopleByDomain collects the names and handles found on each domain, to list the people behind the webring's sites
+type peopleByDomain map[string]*types.Person
+
+func (p peopleByDomain) add(domain, name, kind string) {
+	person, exists := p[domain]
+	if !exists {
+		person = &types.Person{Domain: domain}
+		p[domain] = person
+	}
+	switch kind {
+	case "name":
+		if !find(person.Names, name) {
+			person.Names = append(person.Names, name)
+		}
+	case "handle":
+		if !find(person.Handles, name) {
+			person.Handles = append(person.Handles, name)
+		}
+	}
+}
+
+func (p peopleByDomain) list() []types.Person {
+	people := make([]types.Person, 0, len(p))
+	for _, person := range p {
+		people = append(people, *person)
+	}
+	return people
+}
+
+func getDomain(pageurl string) string {
+	u, err := url.Parse(pageurl)
+	util.Check(err)
+	return u.Hostname()
+}
+
+func ingestExternalPages(db *sql.DB, pageMap map[string]types.PageData) {
+	pages := make([]types.PageData, 0, len(pageMap))
+	for _, page := range pageMap {
+		pages = append(pages, page)
+	}
+	log.Println("starting to ingest external pages (Pages:", len(pages), ")")
+	for i := 0; i < len(pages); i += 1000 {
+		end_i := i + 1000
+		if end_i > len(pages) {
+			end_i = len(pages)
+		}
+		database.InsertManyExternalPages(db, pages[i:end_i])
+	}
+	log.Println("finished ingesting external pages")
+}
+
 func extractPathSegments(pageurl string) []string {
 	u, err := url.Parse(pageurl)
 	util.Check(err)
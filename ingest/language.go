@@ -0,0 +1,80 @@
+package ingest
+
+import (
+	"regexp"
+	"strings"
+
+	"gomod.cblgh.org/lieu/types"
+	"gomod.cblgh.org/lieu/util"
+
+	"github.com/abadojack/whatlanggo"
+)
+
+const (
+	// how much of a page's text is used to guess its language
+	languageSampleSize = 4000
+	// guesses based on less text than this are too unreliable to be used
+	minLanguageSample = 200
+	// a page's declared language is overridden when the guess is at least this confident
+	languageOverrideConfidence = 0.9
+)
+
+var languageCodeRegex = regexp.MustCompile("^[a-z]+$")
+
+// languageSamples collects some of the text of each page, to detect the language of pages that don't declare it
+type languageSamples map[string]*strings.Builder
+
+func (samples languageSamples) add(pageurl, text string) {
+	sample, exists := samples[pageurl]
+	if !exists {
+		sample = &strings.Builder{}
+		samples[pageurl] = sample
+	}
+	if sample.Len() < languageSampleSize {
+		sample.WriteString(text)
+		sample.WriteString("\n")
+	}
+}
+
+// isSuspiciousLanguage reports whether a declared language tells nothing about the page's language; e.g. it is
+// missing, or one of the tags for undetermined (und), non-linguistic (zxx) and multiple (mul) languages
+func isSuspiciousLanguage(lang string) bool {
+	base := util.BaseLanguage(lang)
+	if len(base) < 2 || len(base) > 3 || base == "und" || base == "zxx" || base == "mul" {
+		return true
+	}
+	return !languageCodeRegex.MatchString(base)
+}
+
+// detectLanguages guesses the language of each page using trigram statistics, and uses the guess when the page either
+// doesn't declare its language or—judging by the confidence of the guess—has declared the wrong one; sites often
+// inherit a lang="en" from their theme. the samples of the detected pages are discarded.
+func detectLanguages(pages map[string]types.PageData, samples languageSamples) {
+	for pageurl, page := range pages {
+		sample, exists := samples[pageurl]
+		if !exists {
+			continue
+		}
+		delete(samples, pageurl)
+		if sample.Len() < minLanguageSample {
+			continue
+		}
+
+		info := whatlanggo.Detect(sample.String())
+		detected := info.Lang.Iso6391()
+		if len(detected) == 0 {
+			continue
+		}
+		page.DetectedLang = detected
+		page.LangConfidence = info.Confidence
+
+		if isSuspiciousLanguage(page.Lang) {
+			if info.Confidence >= whatlanggo.ReliableConfidenceThreshold {
+				page.Lang = detected
+			}
+		} else if util.BaseLanguage(page.Lang) != detected && info.Confidence >= languageOverrideConfidence {
+			page.Lang = detected
+		}
+		pages[pageurl] = page
+	}
+}
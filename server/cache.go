@@ -0,0 +1,115 @@
+package server
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"gomod.cblgh.org/lieu/types"
+	"gomod.cblgh.org/lieu/util"
+)
+
+// the number of searches whose results are kept
+const cacheSize = 500
+
+// cachedSearch is what a search results in, before it is rendered
+type cachedSearch struct {
+	pages      []types.PageData
+	images     []types.Image
+	suggestion string
+}
+
+type cacheEntry struct {
+	key    string
+	search cachedSearch
+}
+
+// queryCache keeps the results of the most recent searches, so that popular searches don't query the database each
+// time they are made. the cache is emptied whenever the database is opened again, after ingest has replaced it.
+type queryCache struct {
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	// the most recently used entries are at the front
+	recent *list.List
+	hits   int
+	misses int
+}
+
+func newQueryCache() *queryCache {
+	return &queryCache{entries: make(map[string]*list.Element), recent: list.New()}
+}
+
+// clear empties the cache, keeping its stats
+func (c *queryCache) clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.recent.Init()
+}
+
+// get returns the results of the search with the given key, if they are cached
+func (c *queryCache) get(key string) (cachedSearch, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, exists := c.entries[key]
+	if !exists {
+		c.misses++
+		return cachedSearch{}, false
+	}
+	c.hits++
+	c.recent.MoveToFront(element)
+	return element.Value.(*cacheEntry).search, true
+}
+
+// put caches the results of a search, evicting the least recently used search if the cache is full
+func (c *queryCache) put(key string, search cachedSearch) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, exists := c.entries[key]; exists {
+		element.Value.(*cacheEntry).search = search
+		c.recent.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.recent.PushFront(&cacheEntry{key: key, search: search})
+	if c.recent.Len() > cacheSize {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// CacheStats counts how many searches were answered from the cache
+type CacheStats struct {
+	Hits    int `json:"hits"`
+	Misses  int `json:"misses"`
+	Entries int `json:"entries"`
+}
+
+func (c *queryCache) stats() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.recent.Len()}
+}
+
+// statsRoute reports how well the cache is doing, as json
+func (h RequestHandler) statsRoute(res http.ResponseWriter, req *http.Request) {
+	data, err := json.Marshal(map[string]CacheStats{"cache": h.cache.stats()})
+	util.Check(err)
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.Write(data)
+}
+
+// cacheKey identifies a search by its route and its normalized words and filters, which are sorted as their order
+// doesn't change the results
+func cacheKey(route string, words []string, filters ...[]string) string {
+	parts := []string{route, strings.Join(words, " ")}
+	for _, filter := range filters {
+		sorted := append([]string{}, filter...)
+		sort.Strings(sorted)
+		parts = append(parts, strings.Join(sorted, " "))
+	}
+	return strings.Join(parts, "\x00")
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/url"
+
+	"gomod.cblgh.org/lieu/types"
+)
+
+// diversify keeps at most perSite of each site's pages, so that a site with many matching pages doesn't crowd out the
+// others. the last page kept of a site with more pages links to them, through its MoreFromSite. pages keep their
+// order, and a perSite of 0 (or less) keeps every page.
+func diversify(pages []types.PageData, perSite int) []types.PageData {
+	if perSite <= 0 {
+		return pages
+	}
+	kept := make([]types.PageData, 0, len(pages))
+	// the number of pages kept of each domain, and the index of its last kept page
+	count := make(map[string]int)
+	last := make(map[string]int)
+	for _, page := range pages {
+		u, err := url.Parse(page.URL)
+		if err != nil {
+			continue
+		}
+		domain := u.Hostname()
+		if count[domain] >= perSite {
+			kept[last[domain]].MoreFromSite = domain
+			continue
+		}
+		count[domain]++
+		last[domain] = len(kept)
+		kept = append(kept, page)
+	}
+	return kept
+}
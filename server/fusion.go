@@ -0,0 +1,69 @@
+package server
+
+import (
+	"sort"
+	"strings"
+
+	"gomod.cblgh.org/lieu/types"
+)
+
+// how much the pages ranked at the top of a list count for, compared to those ranked below them. the larger it is,
+// the more a page found in several lists is preferred over one ranked highly in only one of them; 60 is the constant
+// of the paper introducing reciprocal rank fusion.
+const fusionK = 60
+
+// fuse merges ranked lists of pages into one, using reciprocal rank fusion: a page scores 1/(fusionK+rank) for each
+// list it is found in, and the pages are ranked by the sum of their scores. a page found in several lists keeps the
+// data of the first list it is found in, and the paragraphs of the first list that has any for it.
+func fuse(lists ...[]types.PageData) []types.PageData {
+	var pages []types.PageData
+	scores := make(map[string]float64)
+	index := make(map[string]int)
+	for _, list := range lists {
+		for rank, page := range list {
+			scores[page.URL] += 1 / float64(fusionK+rank+1)
+			i, exists := index[page.URL]
+			if !exists {
+				index[page.URL] = len(pages)
+				pages = append(pages, page)
+				continue
+			}
+			if len(pages[i].ParagraphResults) == 0 {
+				pages[i].ParagraphResults = page.ParagraphResults
+			}
+		}
+	}
+	// ties keep the order of the lists
+	sort.SliceStable(pages, func(i, j int) bool {
+		return scores[pages[i].URL] > scores[pages[j].URL]
+	})
+	return pages
+}
+
+// foundAmong keeps the pages of more that are also among pages
+func foundAmong(more, pages []types.PageData) []types.PageData {
+	found := make(map[string]bool)
+	for _, page := range pages {
+		found[page.URL] = true
+	}
+	var kept []types.PageData
+	for _, page := range more {
+		if found[page.URL] {
+			kept = append(kept, page)
+		}
+	}
+	return kept
+}
+
+// fulltextWords builds a fulltext query matching all of the words. words that could be mistaken for fts5 query syntax
+// are quoted, so that they are searched for as they are.
+func fulltextWords(words []string) string {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if !plainWord.MatchString(word) {
+			word = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+		}
+		quoted = append(quoted, word)
+	}
+	return strings.Join(quoted, " ")
+}
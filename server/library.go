@@ -0,0 +1,62 @@
+package server
+
+import (
+	"database/sql"
+	"os"
+	"sync"
+	"time"
+
+	"gomod.cblgh.org/lieu/database"
+)
+
+// the time the replaced database is kept open, for the searches which are still using it
+const closeDelay = time.Minute
+
+// index is an opened database, along with the dictionaries read from it
+type index struct {
+	db          *sql.DB
+	spelling    *spellchecker
+	completions *completer
+}
+
+func openIndex(path string) *index {
+	db := database.InitDB(path)
+	vocabulary := database.GetVocabulary(db)
+	forms := database.GetWordForms(db)
+	return &index{
+		db:          db,
+		spelling:    newSpellchecker(vocabulary, forms),
+		completions: newCompleter(vocabulary, forms, database.GetTitles(db)),
+	}
+}
+
+// library keeps the database open. ingest builds a new database next to the one in use, and moves it into its place
+// once it is complete; the database is then opened again.
+type library struct {
+	mutex   sync.Mutex
+	path    string
+	file    os.FileInfo
+	current *index
+}
+
+func openLibrary(path string) *library {
+	l := &library{path: path, current: openIndex(path)}
+	l.file, _ = os.Stat(path)
+	return l
+}
+
+// index returns the opened database, first opening it again if its file has been replaced. reopened is true when it
+// was opened again.
+func (l *library) index() (current *index, reopened bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	file, err := os.Stat(l.path)
+	if err != nil || (l.file != nil && os.SameFile(file, l.file)) {
+		return l.current, false
+	}
+	previous := l.current
+	l.current = openIndex(l.path)
+	l.file = file
+	time.AfterFunc(closeDelay, func() { previous.db.Close() })
+	return l.current, true
+}
@@ -1,7 +1,6 @@
 package server
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
 	"html/template"
@@ -17,8 +16,10 @@ import (
 )
 
 type RequestHandler struct {
-	config types.Config
-	db     *sql.DB
+	config   types.Config
+	library  *library
+	synonyms synonyms
+	cache    *queryCache
 }
 
 type TemplateView struct {
@@ -27,11 +28,14 @@ type TemplateView struct {
 }
 
 type SearchData struct {
-	Query      string
-	Title      string
-	Site       string
-	Pages      []types.PageData
-	IsInternal bool
+	Query       string
+	Title       string
+	Site        string
+	Pages       []types.PageData
+	Images      []types.Image
+	IsInternal  bool
+	ImageSearch bool
+	Suggestion  string
 }
 
 type IndexData struct {
@@ -44,6 +48,16 @@ type ListData struct {
 	URLs  []types.PageData
 }
 
+type TagsData struct {
+	Title string
+	Tags  []types.Tag
+}
+
+type PeopleData struct {
+	Title  string
+	People []types.Person
+}
+
 type AboutData struct {
 	DomainCount  int
 	WebringName  string
@@ -56,19 +70,325 @@ type AboutData struct {
 
 var templates = template.Must(template.ParseFiles(
 	"html/head.html", "html/nav.html", "html/footer.html",
-	"html/about.html", "html/index.html", "html/list.html", "html/search.html", "html/webring.html"))
+	"html/about.html", "html/index.html", "html/list.html", "html/people.html", "html/search.html", "html/tags.html", "html/webring.html"))
 
 const useURLTitles = true
 
+// searchQuery is a search of the links, with its operators (e.g. site:example.org) separated from its words
+type searchQuery struct {
+	query     string
+	site      string
+	words     []string
+	domains   []string
+	nodomains []string
+	langs     []string
+	authors   []string
+	tags      []string
+}
+
+// parseSearch reads the search of a request: its query, and the site, author and tag params. the words are
+// normalized, but not yet segmented or reduced to the terms they are indexed as.
+func parseSearch(req *http.Request) searchQuery {
+	var s searchQuery
+	if req.Method != http.MethodGet {
+		return s
+	}
+	params := req.URL.Query()
+	if words, exists := params["q"]; exists && words[0] != "" {
+		s.query = words[0]
+		s.words = strings.Fields(util.NormalizeText(s.query))
+	}
+
+	// how to use: https://gist.github.com/cblgh/29991ba0a9e65cccbe14f4afd7c975f1
+	if parts, exists := params["site"]; exists && parts[0] != "" {
+		// make sure we only have the domain, and no protocol prefix
+		s.site = strings.TrimPrefix(parts[0], "https://")
+		s.site = strings.TrimPrefix(s.site, "http://")
+		s.site = strings.TrimSuffix(s.site, "/")
+		s.domains = append(s.domains, s.site)
+	}
+
+	if parts, exists := params["author"]; exists && parts[0] != "" {
+		s.authors = append(s.authors, parts[0])
+	}
+
+	if parts, exists := params["tag"]; exists && parts[0] != "" {
+		s.tags = append(s.tags, parts[0])
+	}
+
+	// don't process if there are too many fields
+	if len(s.words) <= 100 {
+		var words []string
+		for _, word := range s.words {
+			// This could be more efficient by splitting arrays, but I'm going with the more readable version for now
+			if strings.HasPrefix(word, "site:") {
+				s.domains = append(s.domains, strings.TrimPrefix(word, "site:"))
+			} else if strings.HasPrefix(word, "-site:") {
+				s.nodomains = append(s.nodomains, strings.TrimPrefix(word, "-site:"))
+			} else if strings.HasPrefix(word, "lang:") {
+				s.langs = append(s.langs, strings.TrimPrefix(word, "lang:"))
+			} else if strings.HasPrefix(word, "author:") {
+				// author:ada_lovelace searches for "ada lovelace"
+				s.authors = append(s.authors, strings.ReplaceAll(strings.TrimPrefix(word, "author:"), "_", " "))
+			} else if strings.HasPrefix(word, "tag:") {
+				s.tags = append(s.tags, strings.TrimPrefix(word, "tag:"))
+			} else {
+				words = append(words, word)
+			}
+		}
+		s.words = words
+	}
+	return s
+}
+
+// empty is true for searches without anything to search for, which show the index page instead. an author: or tag:
+// query lists the pages of the author or tag, even without any other words.
+func (s searchQuery) empty() bool {
+	return (len(s.words) == 0 && len(s.authors) == 0 && len(s.tags) == 0) || len(s.words) > 100 || len(s.query) >= 8192
+}
+
+// key identifies the search in the cache of the given route
+func (s searchQuery) key(route string) string {
+	return cacheKey(route, s.words, s.domains, s.nodomains, s.langs, s.authors, s.tags)
+}
+
+// index returns the database searched, emptying the cache when the database has been opened again after an ingest
+func (h RequestHandler) index() *index {
+	current, reopened := h.library.index()
+	if reopened {
+		h.cache.clear()
+	}
+	return current
+}
+
+// cached returns the cached results of the search with the given key, or searches and caches them
+func (h RequestHandler) cached(key string, search func() cachedSearch) cachedSearch {
+	// the results of the replaced database are dropped before any of them is looked up
+	h.index()
+	if results, exists := h.cache.get(key); exists {
+		return results
+	}
+	results := search()
+	h.cache.put(key, results)
+	return results
+}
+
+// filtered is true for searches using filters that only the search of the links supports
+func (s searchQuery) filtered() bool {
+	return len(s.langs) > 0 || len(s.authors) > 0 || len(s.tags) > 0
+}
+
+// searchLinks searches the words of the pages for the query, ranking the pages matching its synonyms lower
+func (h RequestHandler) searchLinks(s searchQuery) []types.PageData {
+	queryFields := util.SegmentCJK(s.words)
+	synonymTerms := h.searchTerms(util.SegmentCJK(h.synonyms.expand(queryFields)), s.langs)
+	return database.SearchWords(h.index().db, h.searchTerms(queryFields, s.langs), synonymTerms, true, s.domains, s.nodomains, s.langs, s.authors, s.tags)
+}
+
+// addSnippets shows the text of each page that matches the query, rather than the page's description. pages already
+// showing a matching paragraph are left as they are.
+func (h RequestHandler) addSnippets(pages []types.PageData, s searchQuery) {
+	queryFields := util.SegmentCJK(s.words)
+	urls := make([]string, 0, len(pages))
+	for _, page := range pages {
+		if len(page.ParagraphResults) == 0 {
+			urls = append(urls, page.URL)
+		}
+	}
+	if len(urls) == 0 || len(queryFields) == 0 {
+		return
+	}
+	words := append(append([]string{}, queryFields...), h.synonyms.expand(queryFields)...)
+	snippets := database.GetSnippets(h.index().db, fulltextPhrase(words), urls)
+	for i := range pages {
+		if snippet, exists := snippets[pages[i].URL]; exists {
+			pages[i].ParagraphResults = []template.HTML{snippet}
+		}
+	}
+}
+
+// suggestion corrects the query of a search which found nothing, if any of its words are misspelled
+func (h RequestHandler) suggestion(s searchQuery) string {
+	if corrected, found := h.index().spelling.suggest(util.NormalizeText(s.query), func(word string) []string {
+		return h.searchTerms([]string{word}, s.langs)
+	}); found {
+		return corrected
+	}
+	return ""
+}
+
+// useTitlesOf titles pages by their urls, if useURLTitles is set
+func useTitlesOf(pages []types.PageData) {
+	if !useURLTitles {
+		return
+	}
+	for i, pageData := range pages {
+		prettyURL, err := url.QueryUnescape(strings.TrimPrefix(strings.TrimPrefix(pageData.URL, "http://"), "https://"))
+		util.Check(err)
+		pageData.Title = prettyURL
+		pages[i] = pageData
+	}
+}
+
 func (h RequestHandler) searchRoute(res http.ResponseWriter, req *http.Request) {
+	view := &TemplateView{}
+	s := parseSearch(req)
+	if s.empty() {
+		view.Data = IndexData{Tagline: h.config.General.Tagline, Placeholder: h.config.General.Placeholder}
+		h.renderView(res, "index", view)
+		return
+	}
+
+	results := h.cached(s.key("/"), func() cachedSearch {
+		pages := h.searchLinks(s)
+		// searching a site shows all of its pages
+		if len(s.domains) == 0 {
+			pages = diversify(pages, h.config.General.ResultsPerSite)
+		}
+		if len(pages) > database.PageLimit {
+			pages = pages[:database.PageLimit]
+		}
+		h.addSnippets(pages, s)
+
+		var suggestion string
+		if len(pages) == 0 {
+			suggestion = h.suggestion(s)
+		}
+		useTitlesOf(pages)
+		return cachedSearch{pages: pages, suggestion: suggestion}
+	})
+
+	view.Data = SearchData{
+		Title:       "Link Results",
+		Query:       s.query,
+		Site:        s.site,
+		Pages:       results.pages,
+		IsInternal:  true,
+		ImageSearch: h.config.General.ImageSearch,
+		Suggestion:  results.suggestion,
+	}
+	h.renderView(res, "search", view)
+}
+
+// allSearchRoute merges the results of the link and paragraph searches, showing each page once with the text of it
+// best matching the query
+func (h RequestHandler) allSearchRoute(res http.ResponseWriter, req *http.Request) {
+	view := &TemplateView{}
+	s := parseSearch(req)
+	if s.empty() {
+		view.Data = IndexData{Tagline: h.config.General.Tagline, Placeholder: h.config.General.Placeholder}
+		h.renderView(res, "index", view)
+		return
+	}
+
+	results := h.cached(s.key("/all"), func() cachedSearch {
+		links := h.searchLinks(s)
+		var paragraphs []types.PageData
+		if len(s.words) > 0 {
+			query := fulltextWords(s.words)
+			paragraphs = database.FulltextSearchWholeParagraphs(h.index().db, query, s.domains, s.nodomains)
+			if expanded := h.synonyms.expandFulltext(query); expanded != query {
+				paragraphs = appendUnseen(paragraphs, database.FulltextSearchWholeParagraphs(h.index().db, expanded, s.domains, s.nodomains))
+			}
+			// the paragraph search can't filter by language, author or tag, so only the pages the link search found are
+			// kept when filtering by those
+			if s.filtered() {
+				paragraphs = foundAmong(paragraphs, links)
+			}
+		}
+
+		pages := fuse(links, paragraphs)
+		if len(s.domains) == 0 {
+			pages = diversify(pages, h.config.General.ResultsPerSite)
+		}
+		if len(pages) > database.PageLimit {
+			pages = pages[:database.PageLimit]
+		}
+		// the best matching paragraph of a page is its first; the pages only the link search found get theirs here
+		for i := range pages {
+			if len(pages[i].ParagraphResults) > 1 {
+				pages[i].ParagraphResults = pages[i].ParagraphResults[:1]
+			}
+		}
+		h.addSnippets(pages, s)
+
+		var suggestion string
+		if len(pages) == 0 {
+			suggestion = h.suggestion(s)
+		}
+		useTitlesOf(pages)
+		return cachedSearch{pages: pages, suggestion: suggestion}
+	})
+
+	view.Data = SearchData{
+		Title:       "All Results",
+		Query:       s.query,
+		Site:        s.site,
+		Pages:       results.pages,
+		IsInternal:  true,
+		ImageSearch: h.config.General.ImageSearch,
+		Suggestion:  results.suggestion,
+	}
+	h.renderView(res, "search", view)
+}
+
+// fulltextPhrase builds a fulltext query matching any of the words
+func fulltextPhrase(words []string) string {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		quoted = append(quoted, `"`+strings.ReplaceAll(word, `"`, `""`)+`"`)
+	}
+	return strings.Join(quoted, " OR ")
+}
+
+// searchTerms reduces the words of a query to the terms they are indexed as
+func (h RequestHandler) searchTerms(words, langs []string) []string {
+	terms := util.Inflect(words, langs)
+	if h.config.General.FoldAccents {
+		for i, term := range terms {
+			terms[i] = util.FoldAccents(term)
+		}
+	}
+	return terms
+}
+
+// appendUnseen appends the pages of more which aren't already among pages. the results of a fulltext search
+// expanded with synonyms are appended to those of the query itself, ranking the results only found through synonyms
+// lower.
+func appendUnseen(pages, more []types.PageData) []types.PageData {
+	seen := make(map[string]bool)
+	for _, page := range pages {
+		seen[page.URL] = true
+	}
+	for _, page := range more {
+		if !seen[page.URL] {
+			pages = append(pages, page)
+		}
+	}
+	return pages
+}
+
+func appendUnseenImages(images, more []types.Image) []types.Image {
+	seen := make(map[string]bool)
+	for _, image := range images {
+		seen[image.URL+" "+image.Src] = true
+	}
+	for _, image := range more {
+		if !seen[image.URL+" "+image.Src] {
+			images = append(images, image)
+		}
+	}
+	return images
+}
+
+func (h RequestHandler) paragraphSearchRoute(res http.ResponseWriter, req *http.Request) {
 	var query string
 	var domain string
 	view := &TemplateView{}
 
-	domains := []string{}
-	nodomains := []string{}
-	langs := []string{}
-	queryFields := []string{}
+	var queryFields []string
+	var domains []string
+	var nodomains []string
 
 	if req.Method == http.MethodGet {
 		params := req.URL.Query()
@@ -86,54 +406,90 @@ func (h RequestHandler) searchRoute(res http.ResponseWriter, req *http.Request)
 			domains = append(domains, domain)
 		}
 
+		var newQueryFields []string
 		// don't process if there are too many fields
 		if len(queryFields) <= 100 {
-			var newQueryFields []string
 			for _, word := range queryFields {
 				// This could be more efficient by splitting arrays, but I'm going with the more readable version for now
 				if strings.HasPrefix(word, "site:") {
 					domains = append(domains, strings.TrimPrefix(word, "site:"))
 				} else if strings.HasPrefix(word, "-site:") {
 					nodomains = append(nodomains, strings.TrimPrefix(word, "-site:"))
-				} else if strings.HasPrefix(word, "lang:") {
-					langs = append(langs, strings.TrimPrefix(word, "lang:"))
 				} else {
 					newQueryFields = append(newQueryFields, word)
 				}
 			}
-			queryFields = newQueryFields
+			query = strings.Join(newQueryFields, " ")
 		}
-
 	}
 
-	if len(queryFields) == 0 || len(queryFields) > 100 || len(query) >= 8192 {
-		view.Data = IndexData{Tagline: h.config.General.Tagline, Placeholder: h.config.General.Placeholder}
-		h.renderView(res, "index", view)
-		return
+	// fulltext queries are case sensitive, as e.g. OR is an operator while or is a word
+	results := h.cached(cacheKey("/paragraph", strings.Fields(query), domains, nodomains), func() cachedSearch {
+		pages := database.FulltextSearchWholeParagraphs(h.index().db, query, domains, nodomains)
+		if expanded := h.synonyms.expandFulltext(query); expanded != query {
+			pages = appendUnseen(pages, database.FulltextSearchWholeParagraphs(h.index().db, expanded, domains, nodomains))
+		}
+		if len(domains) == 0 {
+			pages = diversify(pages, h.config.General.ResultsPerSite)
+		}
+		useTitlesOf(pages)
+		return cachedSearch{pages: pages}
+	})
+
+	view.Data = SearchData{
+		Title:       "Paragraph Search Results",
+		Site:        domain,
+		Query:       strings.Join(queryFields, " "),
+		Pages:       results.pages,
+		IsInternal:  false,
+		ImageSearch: h.config.General.ImageSearch,
 	}
+	h.renderView(res, "search", view)
+}
 
-	pages := database.SearchWords(h.db, util.Inflect(queryFields), true, domains, nodomains, langs)
+func (h RequestHandler) externalSearchRoute(res http.ResponseWriter, req *http.Request) {
+	var query string
+	view := &TemplateView{}
 
-	if useURLTitles {
-		for i, pageData := range pages {
-			prettyURL, err := url.QueryUnescape(strings.TrimPrefix(strings.TrimPrefix(pageData.URL, "http://"), "https://"))
-			util.Check(err)
-			pageData.Title = prettyURL
-			pages[i] = pageData
+	if req.Method == http.MethodGet {
+		params := req.URL.Query()
+		if words, exists := params["q"]; exists && words[0] != "" {
+			query = words[0]
 		}
 	}
 
+	results := h.cached(cacheKey("/outgoing", strings.Fields(query)), func() cachedSearch {
+		pages := database.FulltextSearchWords(h.index().db, query)
+		if expanded := h.synonyms.expandFulltext(query); expanded != query {
+			pages = appendUnseen(pages, database.FulltextSearchWords(h.index().db, expanded))
+		}
+
+		// outgoing pages are presented by their titles, if they were found when the links were visited
+		if useURLTitles {
+			for i, pageData := range pages {
+				if len(pageData.Title) > 0 {
+					continue
+				}
+				prettyURL, err := url.QueryUnescape(strings.TrimPrefix(strings.TrimPrefix(pageData.URL, "http://"), "https://"))
+				util.Check(err)
+				pageData.Title = prettyURL
+				pages[i] = pageData
+			}
+		}
+		return cachedSearch{pages: pages}
+	})
+
 	view.Data = SearchData{
-		Title:      "Link Results",
-		Query:      query,
-		Site:       domain,
-		Pages:      pages,
-		IsInternal: true,
+		Title:       "External Results",
+		Query:       query,
+		Pages:       results.pages,
+		IsInternal:  false,
+		ImageSearch: h.config.General.ImageSearch,
 	}
 	h.renderView(res, "search", view)
 }
 
-func (h RequestHandler) paragraphSearchRoute(res http.ResponseWriter, req *http.Request) {
+func (h RequestHandler) imageSearchRoute(res http.ResponseWriter, req *http.Request) {
 	var query string
 	var domain string
 	view := &TemplateView{}
@@ -149,7 +505,6 @@ func (h RequestHandler) paragraphSearchRoute(res http.ResponseWriter, req *http.
 			queryFields = strings.Fields(query)
 		}
 
-		// how to use: https://gist.github.com/cblgh/29991ba0a9e65cccbe14f4afd7c975f1
 		if parts, exists := params["site"]; exists && parts[0] != "" {
 			// make sure we only have the domain, and no protocol prefix
 			domain = strings.TrimPrefix(parts[0], "https://")
@@ -162,7 +517,6 @@ func (h RequestHandler) paragraphSearchRoute(res http.ResponseWriter, req *http.
 		// don't process if there are too many fields
 		if len(queryFields) <= 100 {
 			for _, word := range queryFields {
-				// This could be more efficient by splitting arrays, but I'm going with the more readable version for now
 				if strings.HasPrefix(word, "site:") {
 					domains = append(domains, strings.TrimPrefix(word, "site:"))
 				} else if strings.HasPrefix(word, "-site:") {
@@ -175,54 +529,24 @@ func (h RequestHandler) paragraphSearchRoute(res http.ResponseWriter, req *http.
 		}
 	}
 
-	pages := database.FulltextSearchWholeParagraphs(h.db, query, domains, nodomains)
-
-	if useURLTitles {
-		for i, pageData := range pages {
-			prettyURL, err := url.QueryUnescape(strings.TrimPrefix(strings.TrimPrefix(pageData.URL, "http://"), "https://"))
-			util.Check(err)
-			pageData.Title = prettyURL
-			pages[i] = pageData
-		}
-	}
-
-	view.Data = SearchData{
-		Title:      "Paragraph Search Results",
-		Site:       domain,
-		Query:      strings.Join(queryFields, " "),
-		Pages:      pages,
-		IsInternal: false,
-	}
-	h.renderView(res, "search", view)
-}
-
-func (h RequestHandler) externalSearchRoute(res http.ResponseWriter, req *http.Request) {
-	var query string
-	view := &TemplateView{}
-
-	if req.Method == http.MethodGet {
-		params := req.URL.Query()
-		if words, exists := params["q"]; exists && words[0] != "" {
-			query = words[0]
-		}
-	}
-
-	pages := database.FulltextSearchWords(h.db, query)
-
-	if useURLTitles {
-		for i, pageData := range pages {
-			prettyURL, err := url.QueryUnescape(strings.TrimPrefix(strings.TrimPrefix(pageData.URL, "http://"), "https://"))
-			util.Check(err)
-			pageData.Title = prettyURL
-			pages[i] = pageData
-		}
+	var images []types.Image
+	if len(query) > 0 {
+		images = h.cached(cacheKey("/images", strings.Fields(query), domains, nodomains), func() cachedSearch {
+			images := database.FulltextSearchImages(h.index().db, query, domains, nodomains)
+			if expanded := h.synonyms.expandFulltext(query); expanded != query {
+				images = appendUnseenImages(images, database.FulltextSearchImages(h.index().db, expanded, domains, nodomains))
+			}
+			return cachedSearch{images: images}
+		}).images
 	}
 
 	view.Data = SearchData{
-		Title:      "External Results",
-		Query:      query,
-		Pages:      pages,
-		IsInternal: false,
+		Title:       "Image Results",
+		Site:        domain,
+		Query:       strings.Join(queryFields, " "),
+		Images:      images,
+		IsInternal:  false,
+		ImageSearch: h.config.General.ImageSearch,
 	}
 	h.renderView(res, "search", view)
 }
@@ -230,10 +554,10 @@ func (h RequestHandler) externalSearchRoute(res http.ResponseWriter, req *http.R
 func (h RequestHandler) aboutRoute(res http.ResponseWriter, req *http.Request) {
 	view := &TemplateView{}
 
-	pageCount := util.Humanize(database.GetPageCount(h.db))
-	wordCount := util.Humanize(database.GetWordCount(h.db))
-	domainCount := database.GetDomainCount(h.db)
-	lastCrawl := database.GetLastCrawl(h.db)
+	pageCount := util.Humanize(database.GetPageCount(h.index().db))
+	wordCount := util.Humanize(database.GetWordCount(h.index().db))
+	domainCount := database.GetDomainCount(h.index().db)
+	lastCrawl := database.GetLastCrawl(h.index().db)
 
 	view.Data = AboutData{
 		WebringName:  h.config.General.Name,
@@ -268,13 +592,31 @@ func (h RequestHandler) filteredRoute(res http.ResponseWriter, req *http.Request
 	h.renderView(res, "list", view)
 }
 
+func (h RequestHandler) peopleRoute(res http.ResponseWriter, req *http.Request) {
+	view := &TemplateView{}
+	view.Data = PeopleData{
+		Title:  "People",
+		People: database.GetPeople(h.index().db),
+	}
+	h.renderView(res, "people", view)
+}
+
+func (h RequestHandler) tagsRoute(res http.ResponseWriter, req *http.Request) {
+	view := &TemplateView{}
+	view.Data = TagsData{
+		Title: "Tags",
+		Tags:  database.GetTags(h.index().db, 500),
+	}
+	h.renderView(res, "tags", view)
+}
+
 func (h RequestHandler) randomRoute(res http.ResponseWriter, req *http.Request) {
-	link := database.GetRandomPage(h.db)
+	link := database.GetRandomPage(h.index().db)
 	http.Redirect(res, req, link, http.StatusSeeOther)
 }
 
 func (h RequestHandler) randomExternalRoute(res http.ResponseWriter, req *http.Request) {
-	link := database.GetRandomExternalLink(h.db)
+	link := database.GetRandomExternalLink(h.index().db)
 	http.Redirect(res, req, link, http.StatusSeeOther)
 }
 
@@ -288,7 +630,7 @@ func (h RequestHandler) renderView(res http.ResponseWriter, tmpl string, view *T
 	if _, exists := os.LookupEnv("LIEU_DEV"); exists {
 		templates := template.Must(template.ParseFiles(
 			"html/head.html", "html/nav.html", "html/footer.html",
-			"html/about.html", "html/index.html", "html/list.html", "html/search.html", "html/webring.html"))
+			"html/about.html", "html/index.html", "html/list.html", "html/people.html", "html/search.html", "html/tags.html", "html/webring.html"))
 		errTemp = templates.ExecuteTemplate(res, tmpl+".html", view)
 	} else {
 		errTemp = templates.ExecuteTemplate(res, tmpl+".html", view)
@@ -318,17 +660,29 @@ func WriteTheme(config types.Config) {
 
 func Serve(config types.Config) {
 	WriteTheme(config)
-	db := database.InitDB(config.Data.Database)
-	handler := RequestHandler{config: config, db: db}
+	handler := RequestHandler{
+		config:   config,
+		library:  openLibrary(config.Data.Database),
+		synonyms: readSynonyms(config.Data.Synonyms, config.General.FoldAccents),
+		cache:    newQueryCache(),
+	}
 
 	http.HandleFunc("/about", handler.aboutRoute)
 	http.HandleFunc("/", handler.searchRoute)
+	http.HandleFunc("/all", handler.allSearchRoute)
 	http.HandleFunc("/paragraph", handler.paragraphSearchRoute)
 	http.HandleFunc("/outgoing", handler.externalSearchRoute)
+	if config.General.ImageSearch {
+		http.HandleFunc("/images", handler.imageSearchRoute)
+	}
 	http.HandleFunc("/random/outgoing", handler.randomExternalRoute)
 	http.HandleFunc("/random", handler.randomRoute)
 	http.HandleFunc("/webring", handler.webringRoute)
 	http.HandleFunc("/filtered", handler.filteredRoute)
+	http.HandleFunc("/people", handler.peopleRoute)
+	http.HandleFunc("/tags", handler.tagsRoute)
+	http.HandleFunc("/suggest", handler.suggestRoute)
+	http.HandleFunc("/stats", handler.statsRoute)
 
 	fileserver := http.FileServer(http.Dir("html/"))
 	http.Handle("/assets/", fileserver)
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"gomod.cblgh.org/lieu/util"
+)
+
+// spellchecker proposes corrections for misspelled search terms, using the words of the index as its dictionary
+type spellchecker struct {
+	// the number of pages each indexed word occurs on
+	vocabulary map[string]int
+	// the indexed words, grouped by their length in characters; corrections are at most two characters longer or
+	// shorter than the misspelled word
+	byLength map[int][]string
+	// the form each indexed word was most often written in on the pages, which corrections are suggested as
+	forms map[string]string
+}
+
+func newSpellchecker(vocabulary map[string]int, forms map[string]string) *spellchecker {
+	s := &spellchecker{vocabulary: vocabulary, byLength: make(map[int][]string), forms: forms}
+	for word := range vocabulary {
+		length := utf8.RuneCountInString(word)
+		s.byLength[length] = append(s.byLength[length], word)
+	}
+	return s
+}
+
+// maxEdits is the number of typos allowed in a word; short words have too many neighbours for more than one
+func maxEdits(word string) int {
+	if utf8.RuneCountInString(word) <= 4 {
+		return 1
+	}
+	return 2
+}
+
+// correct returns the indexed word closest to the given one, preferring the words that occur on the most pages
+func (s *spellchecker) correct(word string) (string, bool) {
+	limit := maxEdits(word)
+	length := utf8.RuneCountInString(word)
+	var best string
+	bestDistance := limit + 1
+	for l := length - limit; l <= length+limit; l++ {
+		for _, candidate := range s.byLength[l] {
+			distance := editDistance(word, candidate, limit)
+			if distance < bestDistance || (distance == bestDistance && s.vocabulary[candidate] > s.vocabulary[best]) {
+				best = candidate
+				bestDistance = distance
+			}
+		}
+	}
+	return best, bestDistance <= limit
+}
+
+// suggest rewrites the query with its misspelled words corrected, leaving its operators (such as site:) as they are.
+// a word is considered misspelled when none of the terms it is searched for as are indexed.
+func (s *spellchecker) suggest(query string, terms func(word string) []string) (string, bool) {
+	fields := strings.Fields(query)
+	corrected := false
+	for i, field := range fields {
+		// chinese, japanese and korean text is searched for by its bigrams, which can't be corrected one by one
+		if strings.Contains(field, ":") || utf8.RuneCountInString(field) < 3 || len(util.SegmentCJK([]string{field})) > 1 {
+			continue
+		}
+		known := false
+		forms := terms(field)
+		for _, form := range forms {
+			if s.vocabulary[form] > 0 {
+				known = true
+				break
+			}
+		}
+		if known || len(forms) == 0 {
+			continue
+		}
+		if correction, found := s.correct(forms[0]); found {
+			// suggest e.g. geschichten rather than its stem, geschicht
+			if form, exists := s.forms[correction]; exists {
+				correction = form
+			}
+			fields[i] = correction
+			corrected = true
+		}
+	}
+	return strings.Join(fields, " "), corrected
+}
+
+// editDistance is the damerau-levenshtein (optimal string alignment) distance between a and b, i.e. the number of
+// inserted, deleted, substituted and transposed characters turning one into the other. distances above limit are
+// only computed as far as needed to know that they are above it.
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	// the previous two rows of the distance matrix, and the current one
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+			rowMin = minInt(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return rowMin
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+func minInt(first int, rest ...int) int {
+	m := first
+	for _, v := range rest {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
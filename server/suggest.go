@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"gomod.cblgh.org/lieu/util"
+)
+
+// the number of completions offered for a query
+const maxCompletions = 10
+
+// completer offers completions for partially typed queries, from the titles of the pages and the indexed words
+type completer struct {
+	// the number of pages each word occurs on, by the form it was most often written in rather than its indexed stem
+	vocabulary map[string]int
+	// the words, and the normalized titles, sorted so that those sharing a prefix are next to each other
+	words  []string
+	titles []title
+}
+
+type title struct {
+	normalized string
+	original   string
+}
+
+func newCompleter(vocabulary map[string]int, forms map[string]string, titles []string) *completer {
+	c := &completer{vocabulary: make(map[string]int)}
+	// complete e.g. geschi as geschichten, rather than its stem geschicht
+	for word, count := range vocabulary {
+		if form, exists := forms[word]; exists {
+			word = form
+		}
+		c.vocabulary[word] += count
+	}
+	for word := range c.vocabulary {
+		c.words = append(c.words, word)
+	}
+	sort.Strings(c.words)
+	for _, t := range titles {
+		c.titles = append(c.titles, title{normalized: util.NormalizeText(t), original: t})
+	}
+	sort.Slice(c.titles, func(i, j int) bool {
+		return c.titles[i].normalized < c.titles[j].normalized
+	})
+	return c
+}
+
+// complete returns the titles starting with the query, followed by the query with its last word completed using the
+// words of the index—the words found on the most pages first
+func (c *completer) complete(query string) []string {
+	normalized := util.NormalizeText(strings.TrimSpace(query))
+	completions := make([]string, 0, maxCompletions)
+	if len(normalized) == 0 {
+		return completions
+	}
+
+	i := sort.Search(len(c.titles), func(i int) bool { return c.titles[i].normalized >= normalized })
+	for ; i < len(c.titles) && strings.HasPrefix(c.titles[i].normalized, normalized); i++ {
+		if len(completions) >= maxCompletions/2 {
+			break
+		}
+		completions = append(completions, c.titles[i].original)
+	}
+
+	fields := strings.Fields(normalized)
+	last := fields[len(fields)-1]
+	// operators, such as site:, are left for the user to finish
+	if strings.Contains(last, ":") {
+		return completions
+	}
+	var matches []string
+	for i := sort.SearchStrings(c.words, last); i < len(c.words) && strings.HasPrefix(c.words[i], last); i++ {
+		matches = append(matches, c.words[i])
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return c.vocabulary[matches[i]] > c.vocabulary[matches[j]]
+	})
+	prefix := strings.Join(fields[:len(fields)-1], " ")
+	for _, match := range matches {
+		if len(completions) >= maxCompletions {
+			break
+		}
+		if len(prefix) > 0 {
+			match = prefix + " " + match
+		}
+		completions = append(completions, match)
+	}
+	return completions
+}
+
+// suggestRoute answers with completions for the query q, in the format of the opensearch suggestions extension: see
+// https://github.com/dewitt/opensearch/blob/master/mediawiki/Specifications/OpenSearch/Extensions/Suggestions/1.1/Draft%201.wiki
+func (h RequestHandler) suggestRoute(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query().Get("q")
+	completions := []string{}
+	if len(query) <= 200 {
+		completions = h.index().completions.complete(query)
+	}
+	data, err := json.Marshal([]interface{}{query, completions})
+	util.Check(err)
+	res.Header().Set("Content-Type", "application/x-suggestions+json; charset=utf-8")
+	res.Write(data)
+}
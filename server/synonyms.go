@@ -0,0 +1,121 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+
+	"gomod.cblgh.org/lieu/util"
+)
+
+// synonyms maps words to the other names of the same thing, e.g. zine to fanzine
+type synonyms struct {
+	// the other names of each single word name, keyed by the terms the name is indexed as (see keys)
+	names       map[string][]string
+	foldAccents bool
+}
+
+// readSynonyms reads the synonyms file: each line lists the names of one thing, separated by commas. only the single
+// word names are expanded, but they can be expanded into names of several words.
+func readSynonyms(path string, foldAccents bool) synonyms {
+	s := synonyms{names: make(map[string][]string), foldAccents: foldAccents}
+	for _, line := range util.ReadList(path, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var names []string
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.Join(strings.Fields(util.NormalizeText(name)), " "); len(name) > 0 {
+				names = append(names, name)
+			}
+		}
+		for _, name := range names {
+			if strings.Contains(name, " ") {
+				continue
+			}
+			for _, key := range s.keys(name) {
+				for _, other := range names {
+					if other != name && !contains(s.names[key], other) {
+						s.names[key] = append(s.names[key], other)
+					}
+				}
+			}
+		}
+	}
+	return s
+}
+
+// keys lists the terms a word is indexed as in each of the languages, so that the inflections of a name—e.g.
+// fanzines and synthesisers—are expanded like the name itself. the language is part of the key, leaving apart the
+// words of different languages which happen to share a stem.
+func (s synonyms) keys(word string) []string {
+	word = util.NormalizeText(word)
+	langs := append([]string{"en"}, util.StemmedLanguages...)
+	keys := make([]string, 0, len(langs))
+	for _, lang := range langs {
+		term := util.Stem(word, lang)
+		if s.foldAccents {
+			term = util.FoldAccents(term)
+		}
+		keys = append(keys, lang+" "+term)
+	}
+	return keys
+}
+
+// of returns the other names of a word
+func (s synonyms) of(word string) []string {
+	var names []string
+	normalized := util.NormalizeText(word)
+	for _, key := range s.keys(word) {
+		for _, name := range s.names[key] {
+			if name != normalized && !contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func contains(slice []string, sought string) bool {
+	for _, item := range slice {
+		if item == sought {
+			return true
+		}
+	}
+	return false
+}
+
+// expand returns the words of the synonyms of the given words
+func (s synonyms) expand(words []string) []string {
+	var expanded []string
+	for _, word := range words {
+		for _, synonym := range s.of(word) {
+			expanded = append(expanded, strings.Fields(synonym)...)
+		}
+	}
+	return expanded
+}
+
+// words that can be expanded in a fulltext query; anything else is likely fts5 query syntax, which is left alone
+var plainWord = regexp.MustCompile(`^[\p{L}\p{N}]+$`)
+
+// expandFulltext rewrites a fulltext query so that its words also match their synonyms, e.g. zine becomes
+// (zine OR fanzine)
+func (s synonyms) expandFulltext(query string) string {
+	fields := strings.Fields(query)
+	for i, field := range fields {
+		if !plainWord.MatchString(field) {
+			continue
+		}
+		names := s.of(field)
+		if len(names) == 0 {
+			continue
+		}
+		alternatives := []string{field}
+		for _, name := range names {
+			alternatives = append(alternatives, `"`+strings.ReplaceAll(name, `"`, `""`)+`"`)
+		}
+		fields[i] = "(" + strings.Join(alternatives, " OR ") + ")"
+	}
+	return strings.Join(fields, " ")
+}
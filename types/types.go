@@ -8,49 +8,97 @@ type SearchFragment struct {
 	Word  string
 	URL   string
 	Score int
+	// where on the page the word was found; "body" if left empty
+	Field string
 }
 
 type WholeParagraph struct {
-	Text string
-	URL  string
+	Text        string
+	URL         string
+	Fingerprint uint64
+}
+
+// Link is a link from the webring page Source to URL, described by its anchor text
+type Link struct {
+	URL    string
+	Source string
+	Anchor string
+}
+
+// Image is an image on the page URL, described by its alt text or caption
+type Image struct {
+	Src string
+	URL string
+	Alt string
+}
+
+// Person is who is behind a site of the webring, as they call themselves on it
+type Person struct {
+	Domain  string
+	Names   []string
+	Handles []string
+}
+
+type Tag struct {
+	Name  string
+	Count int
 }
 
 type PageData struct {
-	URL             string
-	Title           string
-	About           string
-	ParagraphResult template.HTML
-	Lang            string
-	AboutSource     string
+	URL              string
+	Title            string
+	About            string
+	ParagraphResults []template.HTML
+	MoreFromSite     string
+	Lang             string
+	DeclaredLang     string
+	DetectedLang     string
+	LangConfidence   float64
+	AboutSource      string
+	TitleSource      string
+	Published        string
+	Author           string
+	Tags             []string
+	NoSnippet        bool
+	Similar          int
+	LinkCount        int
+	LinkedFrom       []string
 }
 
 type Config struct {
 	General struct {
-		Name            string `json:name`
-		Tagline         string `json:tagline`
-		Placeholder     string `json:placeholder`
-		URL             string `json:url`
+		Name            string `json:"name"`
+		Tagline         string `json:"tagline"`
+		Placeholder     string `json:"placeholder"`
+		URL             string `json:"url"`
 		WebringSelector string `json:"webringSelector"`
-		Port            int    `json:port`
-		Proxy           string `json:proxy`
-	} `json:general`
+		Port            int    `json:"port"`
+		Proxy           string `json:"proxy"`
+		ImageSearch     bool   `json:"imageSearch"`
+		FoldAccents     bool   `json:"foldAccents"`
+		ResultsPerSite  int    `json:"resultsPerSite"`
+	} `json:"general"`
 	Theme struct {
 		Foreground string `json:"foreground"`
 		Background string `json:"background"`
 		Links      string `json:"links"`
 	} `json:"theme"`
 	Data struct {
-		Source     string `json:source`
-		Database   string `json:database`
-		Heuristics string `json:heuristics`
-		Wordlist   string `json:wordlist`
-	} `json:data`
+		Source     string `json:"source"`
+		Database   string `json:"database"`
+		Heuristics string `json:"heuristics"`
+		Wordlist   string `json:"wordlist"`
+		Stopwords  string `json:"stopwords"`
+		Synonyms   string `json:"synonyms"`
+	} `json:"data"`
 	Crawler struct {
-		Webring        string `json:webring`
-		BannedDomains  string `json:bannedDomains`
-		BannedSuffixes string `json:bannedSuffixes`
-		BoringWords    string `json:boringWords`
-		BoringDomains  string `json:boringDomains`
-		PreviewQueries string `json:"previewQueryList"`
-	} `json:crawler`
+		Webring           string `json:"webring"`
+		BannedDomains     string `json:"bannedDomains"`
+		BannedSuffixes    string `json:"bannedSuffixes"`
+		BoringWords       string `json:"boringWords"`
+		BoringDomains     string `json:"boringDomains"`
+		PreviewQueries    string `json:"previewQueryList"`
+		BannedURLPatterns string `json:"bannedURLPatterns"`
+		QueryParameters   string `json:"queryParameters"`
+	} `json:"crawler"`
 }
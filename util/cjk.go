@@ -0,0 +1,65 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+// isCJK reports whether a character belongs to a script written without spaces between its words
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) || r == 'ー'
+}
+
+// SegmentCJK splits the chinese, japanese and korean text of words into overlapping bigrams, e.g. 日本語 into 日本 and
+// 本語. without a dictionary there's no telling where one word ends and the next begins, but any word of two or more
+// characters is found by the bigrams it consists of. the other words are left as they are.
+func SegmentCJK(words []string) []string {
+	segmented := make([]string, 0, len(words))
+	for _, word := range words {
+		runes := []rune(word)
+		// split the word into runs of cjk and other characters
+		for start := 0; start < len(runes); {
+			cjk := isCJK(runes[start])
+			end := start
+			for end < len(runes) && isCJK(runes[end]) == cjk {
+				end++
+			}
+			run := runes[start:end]
+			if !cjk || len(run) == 1 {
+				segmented = append(segmented, string(run))
+			} else {
+				for i := 0; i+1 < len(run); i++ {
+					segmented = append(segmented, string(run[i:i+2]))
+				}
+			}
+			start = end
+		}
+	}
+	return segmented
+}
+
+// SegmentCJKText splits the chinese, japanese and korean text of a text into bigrams like SegmentCJK, leaving the rest
+// of the text—e.g. the syntax of a fulltext query—as it is. texts without any such text are returned empty.
+func SegmentCJKText(text string) string {
+	runes := []rune(text)
+	var segmented strings.Builder
+	found := false
+	for start := 0; start < len(runes); {
+		end := start
+		for end < len(runes) && isCJK(runes[end]) {
+			end++
+		}
+		if end == start {
+			segmented.WriteRune(runes[start])
+			start++
+			continue
+		}
+		found = true
+		segmented.WriteString(" " + strings.Join(SegmentCJK([]string{string(runes[start:end])}), " ") + " ")
+		start = end
+	}
+	if !found {
+		return ""
+	}
+	return segmented.String()
+}
@@ -0,0 +1,27 @@
+package util
+
+import (
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeText brings the different ways of writing the same text together, before it is split into words: the
+// compatibility characters of NFKC are replaced (e.g. ﬁ by fi, and full width letters by their usual forms), and
+// case is folded using the unicode rules rather than just lowercased (e.g. ß becomes ss)
+func NormalizeText(s string) string {
+	return cases.Fold().String(norm.NFKC.String(s))
+}
+
+// FoldAccents removes the diacritics of a word, e.g. café becomes cafe
+func FoldAccents(word string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, word)
+	if err != nil {
+		return word
+	}
+	return folded
+}
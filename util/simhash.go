@@ -0,0 +1,56 @@
+package util
+
+import (
+	"hash/fnv"
+	"math/bits"
+)
+
+// fingerprints that differ in at most this many bits are considered near-duplicates
+const NearDuplicateDistance = 3
+
+// SimHash accumulates the features (typically words) of a text into a 64 bit fingerprint, where similar texts get
+// fingerprints that differ in only a few bits. see https://en.wikipedia.org/wiki/SimHash
+type SimHash struct {
+	weights  [64]int32
+	Features int
+}
+
+func (s *SimHash) Add(feature string, weight int) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	for i := 0; i < 64; i++ {
+		if sum&(1<<uint(i)) != 0 {
+			s.weights[i] += int32(weight)
+		} else {
+			s.weights[i] -= int32(weight)
+		}
+	}
+	s.Features++
+}
+
+func (s *SimHash) Sum() uint64 {
+	var fingerprint uint64
+	for i := 0; i < 64; i++ {
+		if s.weights[i] > 0 {
+			fingerprint |= 1 << uint(i)
+		}
+	}
+	return fingerprint
+}
+
+func Fingerprint(words []string) uint64 {
+	var s SimHash
+	for _, word := range words {
+		s.Add(word, 1)
+	}
+	return s.Sum()
+}
+
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func IsNearDuplicate(a, b uint64) bool {
+	return HammingDistance(a, b) <= NearDuplicateDistance
+}
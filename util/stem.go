@@ -0,0 +1,59 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/blevesearch/snowballstem"
+	"github.com/blevesearch/snowballstem/dutch"
+	"github.com/blevesearch/snowballstem/french"
+	"github.com/blevesearch/snowballstem/german"
+	"github.com/blevesearch/snowballstem/spanish"
+	"github.com/blevesearch/snowballstem/swedish"
+	"github.com/jinzhu/inflection"
+)
+
+// the languages, besides english, whose words are reduced to their stems using snowball stemmers
+var StemmedLanguages = []string{"de", "es", "fr", "nl", "sv"}
+
+var stemmers = map[string]func(*snowballstem.Env) bool{
+	"de": german.Stem,
+	"es": spanish.Stem,
+	"fr": french.Stem,
+	"nl": dutch.Stem,
+	"sv": swedish.Stem,
+}
+
+// BaseLanguage returns the primary language subtag of a language tag, e.g. "en" for "en-GB"
+func BaseLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i != -1 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// Stem reduces a word to the form it is indexed under in the given language. english, and the languages without a
+// stemmer, only have their nouns singularized.
+func Stem(word, lang string) string {
+	if stem, exists := stemmers[BaseLanguage(lang)]; exists {
+		env := snowballstem.NewEnv(word)
+		stem(env)
+		return env.Current()
+	}
+	return inflection.Singular(word)
+}
+
+// Inflect reduces query terms to the forms they are indexed under in the given languages. unless a query is filtered
+// using lang:, its language is unknown; each term is then reduced as it would be in every language.
+func Inflect(words []string, langs []string) []string {
+	if len(langs) == 0 || langs[0] == "" {
+		langs = append([]string{"en"}, StemmedLanguages...)
+	}
+	inflected := make([]string, 0, len(words))
+	for _, word := range words {
+		for _, lang := range langs {
+			inflected = append(inflected, Stem(word, lang))
+		}
+	}
+	return DeduplicateSlice(inflected)
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"log"
 	"net"
+	"net/url"
 	"os"
 	"regexp"
 	"strings"
@@ -15,17 +16,45 @@ import (
 
 	"gomod.cblgh.org/lieu/types"
 
-	"github.com/jinzhu/inflection"
 	"github.com/komkom/toml"
 	"github.com/microcosm-cc/bluemonday"
 )
 
-func Inflect(words []string) []string {
-	inflected := make([]string, 0, len(words))
-	for _, word := range words {
-		inflected = append(inflected, inflection.Singular(word))
+// files served when a directory is requested; /post/index.html and /post are treated as the same page
+var defaultIndexFiles = []string{"index.html", "index.htm", "index.php"}
+
+// NormalizeURL rewrites a url into the form lieu stores it in: lowercase scheme and host, no default port, no fragment,
+// no default index file and no trailing slash
+func NormalizeURL(link string) string {
+	link = strings.TrimSpace(link)
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(link, "/")
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
 	}
-	return inflected
+	u.Fragment = ""
+	u.RawFragment = ""
+	for _, index := range defaultIndexFiles {
+		if strings.HasSuffix(strings.ToLower(u.Path), "/"+index) {
+			u.Path = u.Path[:len(u.Path)-len(index)]
+			u.RawPath = ""
+			break
+		}
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+	return u.String()
+}
+
+// NormalizeTag brings the different spellings of a tag together: "#Game Dev" and "game-dev" both become "game-dev"
+func NormalizeTag(tag string) string {
+	tag = NormalizeText(strings.TrimSpace(tag))
+	tag = strings.TrimLeft(tag, "#")
+	return strings.Join(strings.Fields(tag), "-")
 }
 
 func Check(err error) {
@@ -171,6 +200,8 @@ func ReadConfig() types.Config {
 	Check(err)
 
 	var conf types.Config
+	// the defaults of the settings which may be left out of the config; a setting that is present replaces its default
+	conf.General.ResultsPerSite = 3
 	decoder := json.NewDecoder(toml.New(bytes.NewBuffer(data)))
 
 	err = decoder.Decode(&conf)
@@ -186,6 +217,12 @@ name = "Sweet Webring"
 url = "https://example.com/"
 webringSelector = "li > a"
 port = 10001
+# show an images tab, searching the images of the webring by their alt texts & captions
+imageSearch = false
+# ignore accents when searching, e.g. letting cafe find café; requires running ingest again when changed
+foldAccents = false
+# the number of results shown from each site, before linking to more from the site (3 if left out); 0 shows them all
+resultsPerSite = 3
 
 [theme]
 # colors specified in hex (or valid css names) which determine the theme of the lieu instance
@@ -202,6 +239,10 @@ database = "data/searchengine.db"
 heuristics = "data/heuristics.txt"
 # aka stopwords, in the search engine biz: https://en.wikipedia.org/wiki/Stop_word
 wordlist = "data/wordlist.txt"
+# a directory of stopwords for the languages that are stemmed, one file per language (e.g. de.txt)
+stopwords = "data/stopwords"
+# names of the same thing, one comma separated list per line, which searches are expanded with
+synonyms = "data/synonyms.txt"
 
 [crawler]
 # manually curated list of domains, or the output of the precrawl command
@@ -216,6 +257,10 @@ boringWords = "data/boring-words.txt"
 boringDomains = "data/boring-domains.txt"
 # queries to search for finding preview text
 previewQueryList = "data/preview-query-list.txt"
+# regexes matching urls that won't be crawled; lines starting with ! are allowed even if a regex bans them
+bannedURLPatterns = "data/banned-url-patterns.txt"
+# query parameters kept when crawling, per domain; all others are removed from crawled urls
+queryParameters = "data/query-parameters.txt"
 `)
 	err := ioutil.WriteFile("lieu.toml", conf, 0o644)
 	Check(err)